@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/flofriday/websearch/model"
+	"github.com/flofriday/websearch/query"
+	"github.com/flofriday/websearch/store"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// The number of results per page of the api if no limit is given
+const API_DEFAULT_LIMIT = 10
+
+// The most results the api returns per page
+const API_MAX_LIMIT = 100
+
+type apiError struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+type apiSnippetPart struct {
+	Text      string `json:"text"`
+	Highlight bool   `json:"highlight"`
+}
+
+type apiSnippet struct {
+	Text  string           `json:"text"`
+	Parts []apiSnippetPart `json:"parts"`
+}
+
+type apiDocument struct {
+	Id          int64  `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Url         string `json:"url"`
+	Icon        string `json:"icon,omitempty"`
+	Language    string `json:"language,omitempty"`
+}
+
+type apiResult struct {
+	apiDocument
+	Score   float64    `json:"score"`
+	Snippet apiSnippet `json:"snippet"`
+}
+
+type apiSearchResponse struct {
+	Query      string      `json:"query"`
+	Language   string      `json:"language,omitempty"`
+	Correction string      `json:"correction,omitempty"`
+	Page       int         `json:"page"`
+	Limit      int         `json:"limit"`
+	Pages      int64       `json:"pages"`
+	Total      int64       `json:"total"`
+	DurationMs float64     `json:"duration_ms"`
+	Results    []apiResult `json:"results"`
+}
+
+type apiDocumentResponse struct {
+	apiDocument
+	Body string `json:"body"`
+}
+
+func apiErrorResponse(c *fiber.Ctx, status int, message string) error {
+	return c.Status(status).JSON(apiError{
+		Error: apiErrorDetail{Status: status, Message: message},
+	})
+}
+
+func newApiDocument(doc *model.Document) apiDocument {
+	icon := ""
+	if doc.Icon != nil {
+		icon = doc.Icon.String()
+	}
+	return apiDocument{
+		Id:          doc.Index,
+		Title:       doc.Title,
+		Description: doc.Description,
+		Url:         doc.Url.String(),
+		Icon:        icon,
+		Language:    doc.Language,
+	}
+}
+
+func newApiSnippet(snippet *query.Snippet) apiSnippet {
+	parts := make([]apiSnippetPart, len(snippet.Parts))
+	for i, part := range snippet.Parts {
+		parts[i] = apiSnippetPart{Text: part.Text, Highlight: part.Highlight}
+	}
+	return apiSnippet{Text: snippet.String(), Parts: parts}
+}
+
+// GET /api/v1/search?q=&lang=&page=&limit=
+func apiSearchHandler(queryEngine *query.QueryEngine) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		queryText := c.Query("q", "")
+		language := c.Query("lang", "")
+		page := c.QueryInt("page", 1)
+		limit := c.QueryInt("limit", API_DEFAULT_LIMIT)
+
+		if queryText == "" {
+			return apiErrorResponse(c, fiber.StatusBadRequest, "the query parameter 'q' is required")
+		}
+		if page < 1 || page > MAX_PAGE {
+			return apiErrorResponse(c, fiber.StatusBadRequest, "the page must be between 1 and "+strconv.Itoa(MAX_PAGE))
+		}
+		if limit < 1 || limit > API_MAX_LIMIT {
+			return apiErrorResponse(c, fiber.StatusBadRequest, "the limit must be between 1 and "+strconv.Itoa(API_MAX_LIMIT))
+		}
+
+		startTime := time.Now()
+		queryResult, err := queryEngine.Find(queryText, language, (page-1)*limit, limit)
+		var syntaxErr *query.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return apiErrorResponse(c, fiber.StatusBadRequest, "invalid query: "+syntaxErr.Error())
+		}
+		if err != nil {
+			log.Printf("WARNING: Unable to search for '%v' because '%v'", queryText, err)
+			return apiErrorResponse(c, fiber.StatusInternalServerError, "could not load results")
+		}
+
+		results := []apiResult{}
+		for i, doc := range queryResult.Documents {
+			if doc == nil {
+				continue
+			}
+			results = append(results, apiResult{
+				apiDocument: newApiDocument(doc),
+				Score:       queryResult.Scores[i],
+				Snippet:     newApiSnippet(queryResult.Snippets[i]),
+			})
+		}
+
+		return c.JSON(apiSearchResponse{
+			Query:      queryText,
+			Language:   language,
+			Correction: queryResult.Correction,
+			Page:       page,
+			Limit:      limit,
+			Pages:      (queryResult.TotalDocs + int64(limit) - 1) / int64(limit),
+			Total:      queryResult.TotalDocs,
+			DurationMs: float64(time.Since(startTime).Microseconds()) / 1000,
+			Results:    results,
+		})
+	}
+}
+
+// GET /api/v1/documents/:id
+func apiDocumentHandler(documentStore store.DocumentStore) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		index, err := strconv.ParseInt(c.Params("id"), 10, 64)
+		if err != nil {
+			return apiErrorResponse(c, fiber.StatusBadRequest, "the id must be an integer")
+		}
+
+		doc, err := documentStore.Get(index)
+		if err != nil {
+			log.Printf("WARNING: Unable to load document %v because '%v'", index, err)
+			return apiErrorResponse(c, fiber.StatusInternalServerError, "could not load the document")
+		}
+		if doc == nil {
+			return apiErrorResponse(c, fiber.StatusNotFound, "no document with the id "+strconv.FormatInt(index, 10))
+		}
+
+		return c.JSON(apiDocumentResponse{
+			apiDocument: newApiDocument(doc),
+			Body:        doc.Body,
+		})
+	}
+}
+
+// Unknown api routes get a json error too, instead of the plain text one of
+// fiber.
+func apiNotFoundHandler(c *fiber.Ctx) error {
+	return apiErrorResponse(c, fiber.StatusNotFound, "no such endpoint '"+c.Path()+"'")
+}
@@ -3,7 +3,7 @@ package cmd
 import (
 	"database/sql"
 	"log"
-	"net/url"
+	"net"
 	"os"
 	"runtime"
 	"sync"
@@ -11,24 +11,53 @@ import (
 
 	"github.com/flofriday/websearch/curate"
 	"github.com/flofriday/websearch/download"
+	"github.com/flofriday/websearch/frontier"
 	"github.com/flofriday/websearch/index"
 	"github.com/flofriday/websearch/model"
 	"github.com/flofriday/websearch/queue"
+	"github.com/flofriday/websearch/robots"
 	"github.com/flofriday/websearch/store"
 )
 
-func CrawlAndIndex(docLimit int64, sqliteFile string) {
+type CrawlOptions struct {
+	DocLimit    int64
+	SqliteFile  string
+	Resume      bool
+	Seeds       []string
+	SeedsFile   string
+	Scope       *curate.Scope
+	MaxPerHost  int
+	HostDelay   time.Duration
+	AllowedNets []*net.IPNet
+	MaxBodySize int64
+	// Negative to disable the near-duplicate detection
+	MaxDuplicateDistance int
+}
+
+func CrawlAndIndex(opts CrawlOptions) {
 	numIndexers := runtime.NumCPU() * 2
 	numDownloaders := numIndexers * 5
 
+	seeds, err := loadSeeds(opts.Seeds, opts.SeedsFile, opts.Resume)
+	if err != nil {
+		log.Fatalf("Unable to load the seeds '%v'\n", err)
+	}
+
 	// Setup the dependencies
-	discoverQueue := queue.NewChannelQueue[*url.URL](make(chan *url.URL, 100))
-	requestQueue := queue.NewChannelQueue[*model.Request](make(chan *model.Request, docLimit))
+	discoverQueue := queue.NewChannelQueue[*model.Link](make(chan *model.Link, 100))
 	responseQueue := queue.NewChannelQueue[*model.Response](make(chan *model.Response, 100))
 	documentQueue := queue.NewChannelQueue[*model.Response](make(chan *model.Response, numIndexers*2))
 
-	os.Remove(sqliteFile)
-	db, err := sql.Open("sqlite3", sqliteFile+"?_journal=WAL&_synchronous=OFF")
+	// The request queue is host-aware, so that we stay polite to every host
+	guard := download.NewAddressGuard(opts.AllowedNets)
+	robotsCache := robots.NewCache(download.NewClient(guard), download.USER_AGENT)
+	requestQueue := frontier.NewFrontier(robotsCache, opts.MaxPerHost, opts.HostDelay)
+
+	// Without resuming we start from zero
+	if !opts.Resume {
+		os.Remove(opts.SqliteFile)
+	}
+	db, err := sql.Open("sqlite3", opts.SqliteFile+"?_journal=WAL&_synchronous=OFF")
 	if err != nil {
 		log.Fatal("Unable to connect to the db!")
 	}
@@ -42,16 +71,39 @@ func CrawlAndIndex(docLimit int64, sqliteFile string) {
 	if err != nil {
 		log.Fatalf("Unable to connect to the index store '%v'\n", err)
 	}
+	sqlCrawlStore, err := store.NewSQLCrawlStore(db)
+	if err != nil {
+		log.Fatalf("Unable to connect to the crawl store '%v'\n", err)
+	}
+	sqlLinkStore, err := store.NewSQLLinkStore(db)
+	if err != nil {
+		log.Fatalf("Unable to connect to the link store '%v'\n", err)
+	}
+	sqlSuggestionStore, err := store.NewSQLSuggestionStore(db)
+	if err != nil {
+		log.Fatalf("Unable to connect to the suggestion store '%v'\n", err)
+	}
+	startCnt, _ := sqlDocumentStore.Count()
 
-	curator := curate.NewCurator(discoverQueue, requestQueue, responseQueue, documentQueue, docLimit)
-	downloaderPool := download.NewDownloaderPool(requestQueue, responseQueue, numDownloaders)
-	indexerPool := index.NewIndexerPool(discoverQueue, documentQueue, sqlDocumentStore, sqlIndexStore, numIndexers)
+	// Everything that can still discover new urls, so that we know when the
+	// crawl ran out of work before hitting the limit
+	var work sync.WaitGroup
+	curator := curate.NewCurator(discoverQueue, requestQueue, responseQueue, documentQueue, sqlCrawlStore, &work, opts.Scope, opts.DocLimit)
+	if opts.Resume {
+		if err := curator.Resume(); err != nil {
+			log.Fatalf("Unable to resume the crawl '%v'\n", err)
+		}
+	}
+	downloaderPool := download.NewDownloaderPool(requestQueue, responseQueue, robotsCache, sqlCrawlStore, &work, guard, opts.MaxBodySize, numDownloaders)
+	indexerPool := index.NewIndexerPool(discoverQueue, documentQueue, sqlDocumentStore, sqlIndexStore, sqlLinkStore, sqlSuggestionStore, sqlCrawlStore, &work, opts.MaxDuplicateDistance, numIndexers)
+	if err := indexerPool.LoadFingerprints(); err != nil {
+		log.Fatalf("Unable to load the fingerprints '%v'\n", err)
+	}
 
 	// Insert the seed into the discoverQueue
-	seed := []string{"https://en.wikipedia.org/wiki/Computer", "https://en.wikipedia.org/wiki/Medicine"}
-	for _, item := range seed {
-		url, _ := url.Parse(item)
-		discoverQueue.Put(url)
+	work.Add(len(seeds))
+	for _, seed := range seeds {
+		discoverQueue.Put(&model.Link{Url: seed, Depth: 0})
 	}
 
 	// Start the internal curate-download-index pipeline
@@ -79,18 +131,27 @@ func CrawlAndIndex(docLimit int64, sqliteFile string) {
 			s2, _ := requestQueue.Size()
 			s3, _ := responseQueue.Size()
 			s4, _ := documentQueue.Size()
-			log.Printf("Completed: %v, DiscoverQ: %v, RequestQ: %v, ResponseQ: %v, DocumentQ: %v", cnt, s1, s2, s3, s4)
+			log.Printf("Completed: %v, DiscoverQ: %v, RequestQ: %v, ResponseQ: %v, DocumentQ: %v, Dropped: [%v], Failed: [%v]", cnt, s1, s2, s3, s4, curator.Dropped(), downloaderPool.Failures())
 			time.Sleep(time.Millisecond * 1000)
 		}
 	}()
 	wg.Wait()
 
+	log.Println("Index anchor texts")
+	anchorCnt, err := index.BuildAnchorIndex(sqlLinkStore, sqlIndexStore)
+	if err != nil {
+		log.Printf("WARNING: Unable to index the anchor texts because '%v'\n", err)
+	} else {
+		log.Printf("Indexed anchor texts for %v documents\n", anchorCnt)
+	}
+
 	// Print the final statistics
 	log.Println("Optimize DB")
 	sqlIndexStore.Optimize()
 	db.Exec("")
 	log.Println("")
 	cnt, _ := sqlDocumentStore.Count()
+	cnt -= startCnt
 	duration := time.Since(startTime)
 	log.Println(" --- Statistics --- ")
 	log.Printf("Downloaded and indexed %v document in %v\n", cnt, duration)
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flofriday/websearch/curate"
+	"github.com/flofriday/websearch/store"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// A small site with a link out of the scope and a broken link, so it runs
+// out of urls long before the limit.
+var testSite = map[string]string{
+	"/":  `<a href="/a">A</a> <a href="/b">B</a> <a href="https://example.com/">Elsewhere</a>`,
+	"/a": `<p>All about the alpha page.</p> <a href="/b">B</a> <a href="/missing">Missing</a>`,
+	"/b": `<p>Nothing but the beta page here.</p> <a href="/">Home</a>`,
+}
+
+func newTestSite(t *testing.T, pages map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><head><title>Page %v</title></head><body>%v</body></html>", r.URL.Path, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testCrawlOptions(t *testing.T, seed string) CrawlOptions {
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	return CrawlOptions{
+		DocLimit:             50,
+		SqliteFile:           filepath.Join(t.TempDir(), "index.db"),
+		Seeds:                []string{seed},
+		Scope:                &curate.Scope{AllowedDomains: []string{"127.0.0.1"}, MaxDepth: -1},
+		MaxPerHost:           2,
+		AllowedNets:          []*net.IPNet{loopback},
+		MaxBodySize:          1024 * 1024,
+		MaxDuplicateDistance: -1,
+	}
+}
+
+// Runs the crawl and fails if it doesn't end on its own.
+func crawl(t *testing.T, opts CrawlOptions) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		CrawlAndIndex(opts)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("the crawl didn't end after running out of urls")
+	}
+}
+
+func openTestDB(t *testing.T, sqliteFile string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", sqliteFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func countDocuments(t *testing.T, sqliteFile string) int64 {
+	t.Helper()
+	documentStore, err := store.NewSQLDocumentStore(openTestDB(t, sqliteFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cnt, err := documentStore.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cnt
+}
+
+func TestCrawlEndsWhenTheScopeRunsOut(t *testing.T) {
+	server := newTestSite(t, testSite)
+	opts := testCrawlOptions(t, server.URL+"/")
+
+	crawl(t, opts)
+
+	if cnt := countDocuments(t, opts.SqliteFile); cnt != int64(len(testSite)) {
+		t.Errorf("indexed %v documents, want %v", cnt, len(testSite))
+	}
+}
+
+func TestResumingAFinishedCrawlEnds(t *testing.T) {
+	server := newTestSite(t, testSite)
+	opts := testCrawlOptions(t, server.URL+"/")
+	crawl(t, opts)
+
+	opts.Resume = true
+	opts.Seeds = nil
+	crawl(t, opts)
+
+	if cnt := countDocuments(t, opts.SqliteFile); cnt != int64(len(testSite)) {
+		t.Errorf("indexed %v documents, want %v", cnt, len(testSite))
+	}
+}
+
+func TestLinksToNearDuplicatesCountForTheOriginal(t *testing.T) {
+	text := "<p>The very same article about the scheduler of the kernel, published twice.</p>"
+	server := newTestSite(t, map[string]string{
+		"/":     `<a href="/a">Article</a> <a href="/b">Next</a>`,
+		"/a":    text,
+		"/b":    `<p>Links to the copy.</p> <a href="/copy">Copy</a>`,
+		"/copy": text,
+	})
+	opts := testCrawlOptions(t, server.URL+"/")
+	opts.MaxDuplicateDistance = 3
+	crawl(t, opts)
+
+	db := openTestDB(t, opts.SqliteFile)
+	documentStore, err := store.NewSQLDocumentStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkStore, err := store.NewSQLLinkStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cnt, _ := documentStore.Count(); cnt != 3 {
+		t.Errorf("indexed %v documents, want 3 without the copy", cnt)
+	}
+	anchors, err := linkStore.GetAnchors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index, texts := range anchors {
+		doc, err := documentStore.Get(index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Whichever of the two was indexed first is kept
+		if doc.Url.Path == "/a" || doc.Url.Path == "/copy" {
+			if len(texts) != 2 {
+				t.Errorf("expected the anchor texts of the article and its copy, got %v", texts)
+			}
+			return
+		}
+	}
+	t.Errorf("expected anchor texts for the article, got %v", anchors)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"log"
+
+	"github.com/flofriday/websearch/query"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// The number of suggestions shown below the search box
+const SUGGESTION_COUNT = 8
+
+type openSearchUrl struct {
+	Type     string `xml:"type,attr"`
+	Method   string `xml:"method,attr"`
+	Template string `xml:"template,attr"`
+}
+
+type openSearchDescription struct {
+	XMLName       xml.Name        `xml:"http://a9.com/-/spec/opensearch/1.1/ OpenSearchDescription"`
+	ShortName     string          `xml:"ShortName"`
+	Description   string          `xml:"Description"`
+	InputEncoding string          `xml:"InputEncoding"`
+	Urls          []openSearchUrl `xml:"Url"`
+}
+
+// GET /opensearch.xml
+// Lets browsers add websearch as a search engine. The urls must be absolute,
+// so they are built from the url the description was requested with.
+func openSearchHandler(c *fiber.Ctx) error {
+	base := c.BaseURL()
+	description := openSearchDescription{
+		ShortName:     "websearch",
+		Description:   "Let's build a search engine, just for fun",
+		InputEncoding: "UTF-8",
+		Urls: []openSearchUrl{
+			{Type: "text/html", Method: "get", Template: base + "/?q={searchTerms}"},
+			{Type: "application/x-suggestions+json", Method: "get", Template: base + "/api/suggest?q={searchTerms}"},
+		},
+	}
+
+	content, err := xml.MarshalIndent(description, "", "  ")
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, "application/opensearchdescription+xml")
+	return c.Send(append([]byte(xml.Header), content...))
+}
+
+// GET /api/suggest?q=
+// Returns completions in the OpenSearch suggestions format, which is an array
+// of the query and the completions: ["lin", ["linux", "linux kernel"]]
+func suggestHandler(suggester *query.Suggester) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		queryText := c.Query("q", "")
+		suggestions, err := suggester.Suggest(queryText, SUGGESTION_COUNT)
+		if err != nil {
+			log.Printf("WARNING: Unable to suggest completions for '%v' because '%v'", queryText, err)
+			return apiErrorResponse(c, fiber.StatusInternalServerError, "could not load suggestions")
+		}
+
+		err = c.JSON([]interface{}{queryText, suggestions})
+		c.Set(fiber.HeaderContentType, "application/x-suggestions+json")
+		return err
+	}
+}
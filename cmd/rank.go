@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/flofriday/websearch/rank"
+	"github.com/flofriday/websearch/store"
+)
+
+func Rank(sqliteFile string, opts rank.Options) {
+	db, err := sql.Open("sqlite3", sqliteFile+"?_journal=WAL")
+	if err != nil {
+		log.Fatal("Unable to connect to the db!")
+	}
+	defer db.Close()
+
+	// The link store needs the tables of the other stores
+	_, err = store.NewSQLDocumentStore(db)
+	if err != nil {
+		log.Fatalf("Unable to connect to the document store '%v'\n", err)
+	}
+	_, err = store.NewSQLCrawlStore(db)
+	if err != nil {
+		log.Fatalf("Unable to connect to the crawl store '%v'\n", err)
+	}
+	sqlIndexStore, err := store.NewSQLIndexStore(db)
+	if err != nil {
+		log.Fatalf("Unable to connect to the index store '%v'\n", err)
+	}
+	sqlLinkStore, err := store.NewSQLLinkStore(db)
+	if err != nil {
+		log.Fatalf("Unable to connect to the link store '%v'\n", err)
+	}
+
+	startTime := time.Now()
+	nodes, sources, targets, err := sqlLinkStore.GetGraph()
+	if err != nil {
+		log.Fatalf("Unable to load the link graph '%v'\n", err)
+	}
+	log.Printf("Loaded %v documents with %v links\n", len(nodes), len(sources))
+
+	ranks, iterations := rank.PageRank(nodes, sources, targets, opts)
+	log.Printf("Computed PageRank in %v iterations\n", iterations)
+
+	err = sqlIndexStore.PutRanks(ranks)
+	if err != nil {
+		log.Fatalf("Unable to store the ranks '%v'\n", err)
+	}
+	log.Printf("Ranked %v documents in %v\n", len(ranks), time.Since(startTime))
+}
@@ -2,15 +2,30 @@ package cmd
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/flofriday/websearch/query"
 	"github.com/flofriday/websearch/store"
 )
 
-func Search(sqliteFile string, queryText string) {
-	db, err := sql.Open("sqlite3", sqliteFile+"?_journal=WAL")
+type SearchOptions struct {
+	SqliteFile string
+	Query      string
+	// Empty for all languages
+	Language string
+	// The number of results per page
+	Limit int
+	// Starts at 1
+	Page   int
+	Boosts map[int]float64
+}
+
+func Search(opts SearchOptions) {
+	db, err := sql.Open("sqlite3", opts.SqliteFile+"?_journal=WAL")
 	if err != nil {
 		log.Fatal("Unable to connect to the db!")
 	}
@@ -24,22 +39,69 @@ func Search(sqliteFile string, queryText string) {
 	if err != nil {
 		log.Fatalf("Unable to connect to the index store '%v'\n", err)
 	}
+	sqlSuggestionStore, err := store.NewSQLSuggestionStore(db)
+	if err != nil {
+		log.Fatalf("Unable to connect to the suggestion store '%v'\n", err)
+	}
 
-	queryEngine := &query.QueryEngine{
-		DocumentStore: sqlDocumentStore,
-		IndexStore:    sqlIndexStore,
+	queryEngine := query.NewQueryEngine(sqlIndexStore, sqlDocumentStore)
+	for field, boost := range opts.Boosts {
+		queryEngine.FieldBoosts[field] = boost
 	}
+	// The dictionary is only loaded if the query needs a correction
+	queryEngine.SpellChecker = query.NewSpellChecker(sqlSuggestionStore)
 
-	queryResult, err := queryEngine.Find(queryText, 6)
+	queryResult, err := queryEngine.Find(opts.Query, opts.Language, (opts.Page-1)*opts.Limit, opts.Limit)
+	var syntaxErr *query.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		fmt.Fprintf(os.Stderr, "Invalid query: %v\n", syntaxErr)
+		os.Exit(1)
+	}
 	if err != nil {
 		log.Fatalf("Unable to create result because: '%v'\n", err)
 	}
 
-	fmt.Printf("Found %v results for \"%v\"\n", queryResult.TotalDocs, queryText)
+	pages := (queryResult.TotalDocs + int64(opts.Limit) - 1) / int64(opts.Limit)
+	fmt.Printf("Found %v results for \"%v\"", queryResult.TotalDocs, opts.Query)
+	if pages > 1 {
+		fmt.Printf(" (page %v of %v)", opts.Page, pages)
+	}
+	fmt.Println()
+	if queryResult.Correction != "" {
+		fmt.Printf("Did you mean \"%v\"?\n", queryResult.Correction)
+	}
 	fmt.Println()
 
+	highlight := isTerminal(os.Stdout)
 	for i, doc := range queryResult.Documents {
-		fmt.Printf("%d) %s\n%s\n", i+1, doc.Title, doc.Url.String())
+		fmt.Printf("%d) %s\n%s\n", (opts.Page-1)*opts.Limit+i+1, doc.Title, doc.Url.String())
+		fmt.Println(formatSnippet(queryResult.Snippets[i], highlight))
 		fmt.Println()
 	}
 }
+
+// Only terminals understand the escape codes for bold text, files and pipes
+// get the plain snippet.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func formatSnippet(snippet *query.Snippet, highlight bool) string {
+	if !highlight {
+		return snippet.String()
+	}
+
+	var b strings.Builder
+	for _, part := range snippet.Parts {
+		if part.Highlight {
+			b.WriteString("\x1b[1m" + part.Text + "\x1b[0m")
+		} else {
+			b.WriteString(part.Text)
+		}
+	}
+	return b.String()
+}
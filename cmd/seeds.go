@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/flofriday/websearch/curate"
+)
+
+// The seeds we use if the user didn't provide any
+var defaultSeeds = []string{"https://en.wikipedia.org/wiki/Computer", "https://en.wikipedia.org/wiki/Medicine"}
+
+// Reads one url per line, ignoring empty lines and comments starting with #.
+func readSeeds(r io.Reader) ([]string, error) {
+	seeds := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		seeds = append(seeds, line)
+	}
+	return seeds, scanner.Err()
+}
+
+// Collects the seeds from the flags and the seeds file (where "-" means
+// stdin) and validates them. A resumed crawl continues with the urls it
+// already knows, so only a new one falls back to the default seeds.
+func loadSeeds(seeds []string, seedsFile string, resume bool) ([]*url.URL, error) {
+	if seedsFile != "" {
+		var r io.Reader = os.Stdin
+		if seedsFile != "-" {
+			f, err := os.Open(seedsFile)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		fileSeeds, err := readSeeds(r)
+		if err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, fileSeeds...)
+	}
+
+	if len(seeds) == 0 && !resume {
+		seeds = defaultSeeds
+	}
+
+	urls := []*url.URL{}
+	for _, seed := range seeds {
+		uri, err := curate.ParseSeed(seed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed '%v': %w", seed, err)
+		}
+		urls = append(urls, uri)
+	}
+	return urls, nil
+}
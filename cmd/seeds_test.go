@@ -0,0 +1,29 @@
+package cmd
+
+import "testing"
+
+func TestDefaultSeedsOnlyForNewCrawls(t *testing.T) {
+	seeds, err := loadSeeds(nil, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seeds) != len(defaultSeeds) {
+		t.Errorf("a new crawl got the seeds %v, want the defaults", seeds)
+	}
+
+	seeds, err = loadSeeds(nil, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seeds) != 0 {
+		t.Errorf("a resumed crawl got the seeds %v, want none", seeds)
+	}
+
+	seeds, err = loadSeeds([]string{"http://127.0.0.1:8080/"}, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seeds) != 1 || seeds[0].Host != "127.0.0.1:8080" {
+		t.Errorf("a resumed crawl got the seeds %v, want the given one", seeds)
+	}
+}
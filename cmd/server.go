@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
+	"strconv"
 	"time"
 
+	"github.com/flofriday/websearch/index"
 	"github.com/flofriday/websearch/model"
 	"github.com/flofriday/websearch/query"
 	"github.com/flofriday/websearch/store"
@@ -14,33 +18,150 @@ import (
 	"github.com/gofiber/template/html"
 )
 
-func mainHandler(queryEngine *query.QueryEngine) func(*fiber.Ctx) error {
+// The number of results per page
+const PAGE_SIZE = 20
+
+// The highest page that can be requested, so that the offset of the results
+// can't overflow
+const MAX_PAGE = 1000
+
+// The maximum number of pages linked below the results
+const MAX_PAGE_LINKS = 9
+
+type pageLink struct {
+	Number  int
+	Url     string
+	Current bool
+}
+
+// Returns the link to a page of the results for the query.
+func pageUrl(queryText string, language string, page int) string {
+	params := url.Values{}
+	params.Set("q", queryText)
+	if language != "" {
+		params.Set("lang", language)
+	}
+	if page > 1 {
+		params.Set("page", strconv.Itoa(page))
+	}
+	return "/?" + params.Encode()
+}
+
+// Returns the links to the pages around the current one.
+func pageLinks(queryText string, language string, page int, pages int) []pageLink {
+	first := page - MAX_PAGE_LINKS/2
+	if first+MAX_PAGE_LINKS-1 > pages {
+		first = pages - MAX_PAGE_LINKS + 1
+	}
+	if first < 1 {
+		first = 1
+	}
+
+	links := []pageLink{}
+	for number := first; number <= pages && len(links) < MAX_PAGE_LINKS; number++ {
+		links = append(links, pageLink{
+			Number:  number,
+			Url:     pageUrl(queryText, language, number),
+			Current: number == page,
+		})
+	}
+	return links
+}
+
+func mainHandler(queryEngine *query.QueryEngine, suggester *query.Suggester) func(*fiber.Ctx) error {
+	type result struct {
+		*model.Document
+		Snippet *query.Snippet
+	}
+
 	type resultData struct {
-		Documents []*model.Document
+		Results   []result
 		TotalDocs int64
 		Duration  time.Duration
 		Query     string
+		// Set if the query couldn't be parsed
+		Error string
+		// Set if a corrected query finds more results
+		Correction    string
+		CorrectionUrl string
+		// The language filter and the ones to choose from
+		Language  string
+		Languages []index.Language
+		// Only set if there is more than one page
+		Page      int
+		Pages     int
+		PrevUrl   string
+		NextUrl   string
+		PageLinks []pageLink
 	}
 
 	return func(c *fiber.Ctx) error {
-		query := c.Query("q", "")
+		queryText := c.Query("q", "")
+		language := c.Query("lang", "")
+		page := c.QueryInt("page", 1)
+		if page < 1 {
+			page = 1
+		}
+		if page > MAX_PAGE {
+			page = MAX_PAGE
+		}
 
 		// If there is no question just display the home page
-		if query == "" {
-			return c.SendFile("web/view/home.html")
+		if queryText == "" {
+			return c.Render("home", resultData{Language: language, Languages: index.Languages})
 		}
 
 		// Get the results
 		startTime := time.Now()
-		queryResult, err := queryEngine.Find(query, 20)
+		queryResult, err := queryEngine.Find(queryText, language, (page-1)*PAGE_SIZE, PAGE_SIZE)
+		var syntaxErr *query.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return c.Render("results", resultData{
+				Query:     queryText,
+				Error:     syntaxErr.Error(),
+				Language:  language,
+				Languages: index.Languages,
+			})
+		}
 		if err != nil {
 			return c.Status(500).SendString(fmt.Sprintf("Could not load results: '%v'", err))
 		}
+
+		// Queries with results are suggested to others, but only once and
+		// not for every page
+		if queryResult.TotalDocs > 0 && page == 1 {
+			if err := suggester.Remember(queryText); err != nil {
+				log.Printf("WARNING: Unable to remember the query '%v' because '%v'", queryText, err)
+			}
+		}
+		results := make([]result, len(queryResult.Documents))
+		for i, doc := range queryResult.Documents {
+			results[i] = result{Document: doc, Snippet: queryResult.Snippets[i]}
+		}
 		data := resultData{
-			Documents: queryResult.Documents,
+			Results:   results,
 			TotalDocs: queryResult.TotalDocs,
-			Query:     query,
+			Query:     queryText,
 			Duration:  time.Since(startTime),
+			Language:  language,
+			Languages: index.Languages,
+		}
+		if queryResult.Correction != "" {
+			data.Correction = queryResult.Correction
+			data.CorrectionUrl = pageUrl(queryResult.Correction, language, 1)
+		}
+
+		pages := int((queryResult.TotalDocs + PAGE_SIZE - 1) / PAGE_SIZE)
+		if pages > 1 {
+			data.Page = page
+			data.Pages = pages
+			data.PageLinks = pageLinks(queryText, language, page, pages)
+			if page > 1 {
+				data.PrevUrl = pageUrl(queryText, language, page-1)
+			}
+			if page < pages {
+				data.NextUrl = pageUrl(queryText, language, page+1)
+			}
 		}
 
 		// Render the Results
@@ -48,7 +169,7 @@ func mainHandler(queryEngine *query.QueryEngine) func(*fiber.Ctx) error {
 	}
 }
 
-func Serve(addr string, sqliteFile string) {
+func Serve(addr string, sqliteFile string, boosts map[int]float64) {
 
 	// Setup the dependencies
 	db, err := sql.Open("sqlite3", sqliteFile+"?_journal=WAL")
@@ -65,10 +186,20 @@ func Serve(addr string, sqliteFile string) {
 	if err != nil {
 		log.Fatalf("Unable to connect to the index store '%v'\n", err)
 	}
+	sqlSuggestionStore, err := store.NewSQLSuggestionStore(db)
+	if err != nil {
+		log.Fatalf("Unable to connect to the suggestion store '%v'\n", err)
+	}
 
-	queryEngine := &query.QueryEngine{
-		DocumentStore: sqlDocumentStore,
-		IndexStore:    sqlIndexStore,
+	queryEngine := query.NewQueryEngine(sqlIndexStore, sqlDocumentStore)
+	for field, boost := range boosts {
+		queryEngine.FieldBoosts[field] = boost
+	}
+	suggester := query.NewSuggester(sqlSuggestionStore)
+	// The dictionary is loaded upfront, so that no search has to wait for it
+	queryEngine.SpellChecker = query.NewSpellChecker(sqlSuggestionStore)
+	if err := queryEngine.SpellChecker.Load(); err != nil {
+		log.Fatalf("Unable to load the spelling dictionary '%v'\n", err)
 	}
 
 	// Setup the routes
@@ -79,7 +210,15 @@ func Serve(addr string, sqliteFile string) {
 		Views:   templateEngine,
 	})
 
-	app.Get("/", mainHandler(queryEngine))
+	app.Get("/", mainHandler(queryEngine, suggester))
+	app.Get("/opensearch.xml", openSearchHandler)
+	app.Get("/api/suggest", suggestHandler(suggester))
+
+	api := app.Group("/api/v1")
+	api.Get("/search", apiSearchHandler(queryEngine))
+	api.Get("/documents/:id", apiDocumentHandler(sqlDocumentStore))
+	api.Use(apiNotFoundHandler)
+
 	app.Static("/static", "./web/static")
 
 	app.Listen(addr)
@@ -0,0 +1,254 @@
+package curate
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/flofriday/websearch/fp"
+	"github.com/flofriday/websearch/store"
+)
+
+// Query parameters that only track where a visitor came from and never
+// change the content of a page.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+}
+
+func isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+	return trackingParams[key] || strings.HasPrefix(key, "utm_")
+}
+
+// Returns the canonical form of the url, so that different ways to write the
+// same url end up as the same string. The url itself is not modified.
+func Canonicalize(link *url.URL) *url.URL {
+	canonical := *link
+	canonical.User = nil
+	canonical.Fragment = ""
+	canonical.RawFragment = ""
+	canonical.Scheme = strings.ToLower(canonical.Scheme)
+
+	host := strings.ToLower(canonical.Hostname())
+	host = strings.TrimSuffix(host, ".")
+	port := canonical.Port()
+	if (canonical.Scheme == "http" && port == "80") || (canonical.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	canonical.Host = host
+
+	// Work on the escaped path, so that escaped slashes stay escaped
+	escapedPath := normalizeEscapes(removeDotSegments(canonical.EscapedPath()))
+	escapedPath = strings.TrimRight(escapedPath, "/")
+	if path, err := url.PathUnescape(escapedPath); err == nil {
+		canonical.Path = path
+		canonical.RawPath = escapedPath
+	}
+
+	canonical.RawQuery = canonicalQuery(canonical.RawQuery)
+	canonical.ForceQuery = false
+	return &canonical
+}
+
+// Returns the key under which the page of the url is known. Most sites serve
+// the same pages over http and https, so both schemes share a key. The url
+// that is requested keeps its scheme, as not every site supports https.
+func URLKey(link *url.URL) string {
+	return store.URLKey(Canonicalize(link).String())
+}
+
+// Sorts the query parameters and removes the tracking ones.
+func canonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	params := []string{}
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil && isTrackingParam(unescaped) {
+			continue
+		}
+		params = append(params, normalizeEscapes(param))
+	}
+
+	// A stable sort keeps the order of repeated keys, which might matter
+	sort.SliceStable(params, func(i, j int) bool {
+		ki, _, _ := strings.Cut(params[i], "=")
+		kj, _, _ := strings.Cut(params[j], "=")
+		return ki < kj
+	})
+	return strings.Join(params, "&")
+}
+
+func isUnreserved(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// Decodes percent-escapes of unreserved characters and uppercases all other
+// escapes as RFC 3986 recommends.
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			hi, ok1 := unhex(s[i+1])
+			lo, ok2 := unhex(s[i+2])
+			if ok1 && ok2 {
+				c := hi<<4 | lo
+				if isUnreserved(c) {
+					b.WriteByte(c)
+				} else {
+					b.WriteString(strings.ToUpper(s[i : i+3]))
+				}
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Resolves "." and ".." segments as described in RFC 3986 section 5.2.4.
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	output := []string{}
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				output = append(output, "")
+			}
+		case "..":
+			// Never remove the leading empty segment of an absolute path
+			if len(output) > 1 {
+				output = output[:len(output)-1]
+			}
+			if last {
+				output = append(output, "")
+			}
+		default:
+			output = append(output, segment)
+		}
+	}
+	return strings.Join(output, "/")
+}
+
+// Whether both urls belong to the same site, which is the registrable domain
+// like example.com for www.example.com. Hosts without one, like IP addresses,
+// must be the same.
+func sameSite(a *url.URL, b *url.URL) bool {
+	hostA := strings.ToLower(a.Hostname())
+	hostB := strings.ToLower(b.Hostname())
+	if hostA == hostB {
+		return true
+	}
+	if net.ParseIP(hostA) != nil || net.ParseIP(hostB) != nil {
+		return false
+	}
+
+	domainA, err := publicsuffix.EffectiveTLDPlusOne(hostA)
+	if err != nil {
+		return false
+	}
+	domainB, err := publicsuffix.EffectiveTLDPlusOne(hostB)
+	if err != nil {
+		return false
+	}
+	return domainA == domainB
+}
+
+// Searches the head of the html document for a <link rel="canonical"> and
+// returns the url it points to.
+func findCanonical(content string, baseURL *url.URL) *url.URL {
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return nil
+
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if string(name) == "head" {
+				return nil
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			if string(name) == "body" {
+				return nil
+			}
+			if string(name) != "link" || !hasAttr {
+				continue
+			}
+
+			var rel, href string
+			for {
+				key, val, more := tokenizer.TagAttr()
+				switch string(key) {
+				case "rel":
+					rel = strings.ToLower(string(val))
+				case "href":
+					href = string(val)
+				}
+				if !more {
+					break
+				}
+			}
+
+			isCanonical := fp.Any(strings.Fields(rel), func(r string) bool { return r == "canonical" })
+			if !isCanonical || href == "" {
+				continue
+			}
+			link, err := baseURL.Parse(strings.TrimSpace(href))
+			if err != nil {
+				return nil
+			}
+			return link
+		}
+	}
+}
@@ -1,37 +1,57 @@
 package curate
 
 import (
+	"errors"
 	"log"
 	"net/url"
 	"strings"
 	"sync"
 
+	"github.com/flofriday/websearch/fp"
 	"github.com/flofriday/websearch/model"
 	"github.com/flofriday/websearch/queue"
+	"github.com/flofriday/websearch/stats"
+	"github.com/flofriday/websearch/store"
 )
 
 type Curator struct {
-	discoverQueue queue.Queue[*url.URL]
+	discoverQueue queue.Queue[*model.Link]
 	requestQueue  queue.Queue[*model.Request]
 	responseQueue queue.Queue[*model.Response]
 	documentQueue queue.Queue[*model.Response]
+	crawlStore    store.CrawlStore
+	scope         *Scope
+
+	// Requests that aren't indexed or failed yet and links that aren't
+	// curated yet. Once there are none left, nothing can discover new urls
+	// and the crawl is over.
+	work      *sync.WaitGroup
+	closeOnce sync.Once
 
 	// FIXME: If that ever becomes a bottle-neck, a tries datastucture would fit
 	// quite nice for this usecase.
-	seenURLs    map[string]bool
-	indexedURLs map[string]bool
-	idCounter   int64
-	limit       int64
-	lock        sync.RWMutex
+	seenURLs map[string]bool
+	// The document every indexed url belongs to
+	indexedURLs   map[string]int64
+	queryVariants queryVariants
+	pending       []*model.Request
+	idCounter     int64
+	issued        int64
+	limit         int64
+	dropped       stats.Counter[string]
+	lock          sync.RWMutex
 }
 
 // FIXME: The constructor here makes sense but since it need so many arguments
 // maybe a single option argument would be nicer
 func NewCurator(
-	discoverQueue queue.Queue[*url.URL],
+	discoverQueue queue.Queue[*model.Link],
 	requestQueue queue.Queue[*model.Request],
 	responseQueue queue.Queue[*model.Response],
 	documentQueue queue.Queue[*model.Response],
+	crawlStore store.CrawlStore,
+	work *sync.WaitGroup,
+	scope *Scope,
 	limit int64,
 ) *Curator {
 	return &Curator{
@@ -39,39 +59,145 @@ func NewCurator(
 		requestQueue:  requestQueue,
 		responseQueue: responseQueue,
 		documentQueue: documentQueue,
+		crawlStore:    crawlStore,
+		work:          work,
+		scope:         scope,
 		seenURLs:      map[string]bool{},
-		indexedURLs:   map[string]bool{},
+		indexedURLs:   map[string]int64{},
+		queryVariants: queryVariants{},
 		idCounter:     0,
 		limit:         limit,
 	}
 }
 
-// Removes part of the url which should not make a difference for our usecase
-func normalize(link *url.URL) *url.URL {
-	link.Fragment = ""
-	link.Path = strings.TrimRight(link.Path, "/")
-	return link
+// Parses and normalizes a seed url given by the user, which must be an
+// absolute http(s) url.
+func ParseSeed(raw string) (*url.URL, error) {
+	link, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	if link.Scheme != "http" && link.Scheme != "https" {
+		return nil, errors.New("only http and https urls are supported")
+	}
+	if link.Host == "" {
+		return nil, errors.New("the url has no host")
+	}
+	return Canonicalize(link), nil
 }
 
-// Filters the url, wether it should be blocked or not
-func isUseful(link *url.URL) bool {
-	// FIXME: Implement filtering, like images, etc
+// Filters the url, wether it should be blocked or not and counts why it was
+// blocked.
+func (c *Curator) isUseful(link *url.URL, depth int) bool {
+	reason := filter(link)
+	if reason == "" && !c.scope.Contains(link, depth) {
+		reason = DROP_SCOPE
+	}
+
+	if reason != "" {
+		c.dropped.Add(reason)
+		return false
+	}
 	return true
 }
 
+// A summary of why urls were dropped, meant for the status log.
+func (c *Curator) Dropped() string {
+	return c.dropped.String()
+}
+
 func (c *Curator) addSeenURL(link *url.URL) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	c.seenURLs[link.String()] = true
+	c.seenURLs[URLKey(link)] = true
 }
 
 func (c *Curator) hasSeenURL(link *url.URL) bool {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
-	_, ok := c.seenURLs[link.String()]
+	_, ok := c.seenURLs[URLKey(link)]
 	return ok
 }
 
+// Loads the state of a previous crawl from the crawl store, so that this crawl
+// continues where the last one stopped. Urls that were requested but never
+// indexed are requested again, followed by the urls that were discovered but
+// never requested.
+// Must be called before Run.
+func (c *Curator) Resume() error {
+	crawlURLs, err := c.crawlStore.GetAll()
+	if err != nil {
+		return err
+	}
+
+	discovered := []*model.Request{}
+	for _, crawlURL := range crawlURLs {
+		uri, err := url.Parse(crawlURL.Url)
+		if err != nil {
+			continue
+		}
+
+		c.seenURLs[URLKey(uri)] = true
+		trap := c.queryVariants.tooMany(uri)
+		if crawlURL.Index >= c.idCounter {
+			c.idCounter = crawlURL.Index + 1
+		}
+
+		// The scope might have changed since the last crawl
+		if crawlURL.State != store.URL_INDEXED && !c.scope.Contains(uri, crawlURL.Depth) {
+			continue
+		}
+
+		switch crawlURL.State {
+		case store.URL_FAILED:
+			// Failed urls are not retried
+		case store.URL_INDEXED:
+			c.indexedURLs[URLKey(uri)] = crawlURL.Index
+		case store.URL_REQUESTED:
+			c.pending = append(c.pending, &model.Request{Index: crawlURL.Index, Url: uri, Depth: crawlURL.Depth})
+		case store.URL_DISCOVERED:
+			// All discovered links are stored, not only the useful ones
+			if filter(uri) != "" || trap {
+				continue
+			}
+			discovered = append(discovered, &model.Request{Url: uri, Depth: crawlURL.Depth})
+		}
+	}
+
+	for _, request := range discovered {
+		request.Index = c.idCounter
+		c.idCounter++
+		c.pending = append(c.pending, request)
+	}
+
+	log.Printf("Resuming crawl with %v known urls of which %v are pending\n", len(crawlURLs), len(c.pending))
+	return nil
+}
+
+// Passes the request on to the downloaders, if we haven't hit the limit yet.
+func (c *Curator) issue(request *model.Request) bool {
+	// FIXME: This is the wrong place to limit the size, because the
+	// submitted documents here can still fail later down the pipeline.
+	// We should probably do this by monitoring the documentstore.
+	if c.issued >= c.limit {
+		return false
+	}
+	c.issued++
+	c.work.Add(1)
+
+	err := c.crawlStore.PutURL(&store.CrawlURL{
+		Url:   request.Url.String(),
+		Index: request.Index,
+		Depth: request.Depth,
+		State: store.URL_REQUESTED,
+	})
+	if err != nil {
+		log.Printf("WARNING: Unable to store the crawl state of %v because '%v'", request.Url.String(), err)
+	}
+	c.requestQueue.Put(request)
+	return true
+}
+
 func (c *Curator) Run() {
 	var wg sync.WaitGroup
 	wg.Add(2)
@@ -91,52 +217,93 @@ func (c *Curator) Run() {
 // Curate the discovered URLs and decide which should be passed on to the
 // request queue and which ones should be filtered out.
 func (c *Curator) curateDiscover() {
-	for {
-		uri, err := c.discoverQueue.Get()
-		if err != nil {
-			log.Println("Curator is exiting, discoverqueue broken")
+	// Continue with the requests a previous crawl didn't finish
+	for _, target := range c.pending {
+		if !c.issue(target) {
 			break
 		}
-		uri = normalize(uri)
-
-		if !isUseful(uri) {
-			continue
-		}
-
-		if c.hasSeenURL(uri) {
-			// Already seen
-			continue
-		}
-		c.addSeenURL(uri)
-
-		// FIXME: Add additional url filters here
+	}
+	c.pending = nil
 
-		target := &model.Request{
-			Index: c.idCounter,
-			Url:   uri,
-		}
-		c.idCounter++
+	// The downloaders and indexers feed each other through us in a cycle, so
+	// without any work left nobody would ever close a queue.
+	go func() {
+		c.work.Wait()
+		c.closeRequests()
+	}()
 
-		// FIXME: This is the wrong place to limit the size, because the
-		// submitted documents here can still fail later down the pipeline.
-		// We should probably do this by monitoring the documentstore.
-		if c.idCounter > c.limit {
+	for c.issued < c.limit {
+		link, err := c.discoverQueue.Get()
+		if err != nil {
+			log.Println("Curator is exiting, discoverqueue broken")
 			break
 		}
-
-		c.requestQueue.Put(target)
+		c.curateLink(link)
+		c.work.Done()
 	}
 
-	// Close the output queue because we have submitted enough documents
-	log.Println("Close request queue")
-	c.requestQueue.Close()
+	// We have submitted enough documents
+	c.closeRequests()
 
-	// Keep draining the discover queue
+	// Keep draining the discover queue, the indexer already stored the links
+	// so that a later crawl can be resumed with them.
 	for {
 		_, err := c.discoverQueue.Get()
 		if err != nil {
 			break
 		}
+		c.work.Done()
+	}
+}
+
+func (c *Curator) curateLink(link *model.Link) {
+	uri := Canonicalize(link.Url)
+
+	if !c.isUseful(uri, link.Depth) {
+		return
+	}
+
+	if c.hasSeenURL(uri) {
+		// Already seen
+		return
+	}
+	c.addSeenURL(uri)
+
+	if c.queryVariants.tooMany(uri) {
+		c.dropped.Add(DROP_TRAP)
+		return
+	}
+
+	target := &model.Request{
+		Index: c.idCounter,
+		Url:   uri,
+		Depth: link.Depth,
+	}
+	c.idCounter++
+	c.issue(target)
+}
+
+// Closes the request queue, either because we hit the limit or because there
+// is nothing left to crawl.
+func (c *Curator) closeRequests() {
+	c.closeOnce.Do(func() {
+		log.Println("Close request queue")
+		c.requestQueue.Close()
+	})
+}
+
+// Remembers that the urls are done, so a resumed crawl won't request them
+// again. The index is the document they belong to, or negative if unknown.
+func (c *Curator) markDone(links []*url.URL, index int64, state int) {
+	if len(links) == 0 {
+		return
+	}
+	crawlURLs := fp.Map(links, func(link *url.URL) *store.CrawlURL {
+		return &store.CrawlURL{Url: link.String(), Index: index, Depth: -1, State: state}
+	})
+	err := c.crawlStore.PutURLs(crawlURLs)
+	if err != nil {
+		log.Printf("WARNING: Unable to store the crawl state of %v because '%v'", crawlURLs[0].Url, err)
 	}
 }
 
@@ -150,23 +317,45 @@ func (c *Curator) curateResponse() {
 			break
 		}
 
-		uri := normalize(response.Url)
+		uri := Canonicalize(response.Url)
+		aliases := fp.Map(response.Redirected, Canonicalize)
 
-		if !isUseful(uri) {
+		// Redirects can lead us out of the scope
+		if !c.isUseful(uri, response.Depth) {
+			c.markDone(append(aliases, uri), response.Index, store.URL_FAILED)
+			c.work.Done()
 			continue
 		}
 
-		if _, ok := c.indexedURLs[uri.String()]; ok {
-			// Already indexed
+		// The page might tell us under which url it wants to be known, in
+		// that case the url we downloaded is just an alias. Only pages of the
+		// same site are trusted, otherwise any page could take over the url
+		// of another site.
+		if canonical := findCanonical(response.Content, response.Url); canonical != nil {
+			canonical = Canonicalize(canonical)
+			if canonical.String() != uri.String() && sameSite(canonical, uri) && filter(canonical) == "" && c.scope.Contains(canonical, response.Depth) {
+				aliases = append(aliases, uri)
+				uri = canonical
+			}
+		}
+
+		if index, ok := c.indexedURLs[URLKey(uri)]; ok {
+			// Already indexed, links to the aliases count for that document
+			c.markDone(aliases, index, store.URL_INDEXED)
+			c.work.Done()
 			continue
 		}
 
+		// The indexer marks the urls as indexed once the document is stored,
+		// until then a resumed crawl requests them again
 		c.addSeenURL(uri)
-		c.indexedURLs[uri.String()] = true
-		for _, redirectUri := range response.Redirected {
-			c.addSeenURL(redirectUri)
-			c.indexedURLs[redirectUri.String()] = true
+		c.indexedURLs[URLKey(uri)] = response.Index
+		for _, alias := range aliases {
+			c.addSeenURL(alias)
+			c.indexedURLs[URLKey(alias)] = response.Index
 		}
+		response.Canonical = uri
+		response.Aliases = aliases
 
 		// FIXME: Add additional url filters here
 
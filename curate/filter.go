@@ -0,0 +1,114 @@
+package curate
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+const MAX_URL_LEN = 1024
+const MAX_QUERY_PARAMS = 8
+const MAX_PATH_SEGMENTS = 16
+const MAX_SEGMENT_REPEATS = 3
+
+// How many different query strings a single page may have
+const MAX_QUERY_VARIANTS = 100
+
+// Why the curator dropped an url.
+const (
+	DROP_SCHEME    = "scheme"
+	DROP_EXTENSION = "extension"
+	DROP_TOO_LONG  = "too-long"
+	DROP_TRAP      = "trap"
+	DROP_SCOPE     = "scope"
+)
+
+// Extensions of files that are almost never html, so there is no point in
+// downloading them.
+var binaryExtensions = map[string]bool{
+	// Images
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
+	".svg": true, ".ico": true, ".bmp": true, ".tif": true, ".tiff": true,
+	".avif": true,
+	// Audio and video
+	".mp3": true, ".mp4": true, ".m4a": true, ".wav": true, ".ogg": true,
+	".oga": true, ".ogv": true, ".webm": true, ".avi": true, ".mov": true,
+	".mkv": true, ".flv": true, ".flac": true,
+	// Archives and executables
+	".zip": true, ".gz": true, ".tgz": true, ".bz2": true, ".xz": true,
+	".7z": true, ".rar": true, ".tar": true, ".exe": true, ".msi": true,
+	".dmg": true, ".iso": true, ".apk": true, ".deb": true, ".rpm": true,
+	".bin": true, ".jar": true,
+	// Documents and data
+	".pdf": true, ".doc": true, ".docx": true, ".xls": true, ".xlsx": true,
+	".ppt": true, ".pptx": true, ".odt": true, ".ods": true, ".odp": true,
+	".csv": true, ".json": true, ".xml": true, ".rss": true, ".atom": true,
+	".txt": true,
+	// Web assets
+	".css": true, ".js": true, ".woff": true, ".woff2": true, ".ttf": true,
+	".otf": true, ".eot": true,
+}
+
+// Checks the url and returns why it should be dropped, or an empty string if
+// it is worth crawling.
+func filter(link *url.URL) string {
+	if link.Scheme != "http" && link.Scheme != "https" {
+		return DROP_SCHEME
+	}
+
+	if len(link.String()) > MAX_URL_LEN {
+		return DROP_TOO_LONG
+	}
+
+	if binaryExtensions[strings.ToLower(path.Ext(link.Path))] {
+		return DROP_EXTENSION
+	}
+
+	if isTrap(link) {
+		return DROP_TRAP
+	}
+
+	return ""
+}
+
+// Detects urls that look like they were generated endlessly, like calendars
+// with a next-month link or relative links that keep nesting the path.
+func isTrap(link *url.URL) bool {
+	if len(link.Query()) > MAX_QUERY_PARAMS {
+		return true
+	}
+
+	segments := strings.Split(strings.Trim(link.Path, "/"), "/")
+	if len(segments) > MAX_PATH_SEGMENTS {
+		return true
+	}
+
+	repeats := map[string]int{}
+	for _, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		repeats[segment]++
+		if repeats[segment] > MAX_SEGMENT_REPEATS {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Counts the distinct query strings of every host and path. Calendars and
+// other endless listings keep generating new ones for the same page, like
+// /cal?month=2024-01, /cal?month=2024-02 and so on.
+type queryVariants map[string]int
+
+// Counts the query string of an url that wasn't seen before and reports
+// whether its host and path already have too many.
+func (v queryVariants) tooMany(link *url.URL) bool {
+	if link.RawQuery == "" {
+		return false
+	}
+	page := link.Host + link.EscapedPath()
+	v[page]++
+	return v[page] > MAX_QUERY_VARIANTS
+}
@@ -0,0 +1,59 @@
+package curate
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://example.com/", ""},
+		{"https://example.com/page?a=1", ""},
+		{"ftp://example.com/file", DROP_SCHEME},
+		{"https://example.com/image.PNG", DROP_EXTENSION},
+		{"https://example.com/" + strings.Repeat("a", MAX_URL_LEN), DROP_TOO_LONG},
+		{"https://example.com/a?1=1&2=2&3=3&4=4&5=5&6=6&7=7&8=8&9=9", DROP_TRAP},
+		{"https://example.com/a/b/a/b/a/b/a/b", DROP_TRAP},
+	}
+
+	for _, test := range tests {
+		link, err := url.Parse(test.link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := filter(link); got != test.want {
+			t.Errorf("filter(%.60q) = %q, want %q", test.link, got, test.want)
+		}
+	}
+}
+
+func TestQueryVariantsCatchCalendars(t *testing.T) {
+	variants := queryVariants{}
+	for i := 0; i < MAX_QUERY_VARIANTS; i++ {
+		year, month := 2000+i/12, i%12+1
+		link, _ := url.Parse(fmt.Sprintf("https://example.com/cal?month=%04d-%02d", year, month))
+		if variants.tooMany(link) {
+			t.Fatalf("expected %v to be crawled", link)
+		}
+	}
+
+	next, _ := url.Parse("https://example.com/cal?month=2100-01")
+	if !variants.tooMany(next) {
+		t.Errorf("expected %v to be a trap", next)
+	}
+
+	// Other pages and the page without a query are still fine
+	other, _ := url.Parse("https://example.com/news?month=2100-01")
+	if variants.tooMany(other) {
+		t.Errorf("expected %v to be crawled", other)
+	}
+	plain, _ := url.Parse("https://example.com/cal")
+	if variants.tooMany(plain) {
+		t.Errorf("expected %v to be crawled", plain)
+	}
+}
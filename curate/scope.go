@@ -0,0 +1,100 @@
+package curate
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// The Scope limits which part of the web a crawl is allowed to visit.
+// Empty lists don't restrict anything.
+type Scope struct {
+	// Hosts must be one of these domains or one of their subdomains
+	AllowedDomains []string
+	// Hosts must not be any of these domains or their subdomains
+	DeniedDomains []string
+	// The path must match at least one of these patterns
+	Include []*regexp.Regexp
+	// The path must not match any of these patterns
+	Exclude []*regexp.Regexp
+	// How many links away from a seed we may go, negative for no limit
+	MaxDepth int
+}
+
+// Compiles a pattern for the Include and Exclude lists. Patterns starting
+// with `re:` are regular expressions, all others are globs where `**` matches
+// everything, `*` everything except a slash and `?` a single character.
+// Globs must match the whole path, regular expressions any part of it.
+func CompilePattern(pattern string) (*regexp.Regexp, error) {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		return regexp.Compile(expr)
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				expr.WriteString(".*")
+				i++
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+func matchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(path string, patterns []*regexp.Regexp) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks whether the url, found depth links away from a seed, is inside the
+// scope.
+func (s *Scope) Contains(link *url.URL, depth int) bool {
+	if s.MaxDepth >= 0 && depth > s.MaxDepth {
+		return false
+	}
+
+	host := strings.ToLower(link.Hostname())
+	if len(s.AllowedDomains) > 0 && !matchesDomain(host, s.AllowedDomains) {
+		return false
+	}
+	if matchesDomain(host, s.DeniedDomains) {
+		return false
+	}
+
+	path := link.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if len(s.Include) > 0 && !matchesAny(path, s.Include) {
+		return false
+	}
+	if matchesAny(path, s.Exclude) {
+		return false
+	}
+
+	return true
+}
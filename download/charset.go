@@ -0,0 +1,54 @@
+package download
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Guesses whether the body is UTF-16 without a byte order mark. Html documents
+// start with '<', which has a zero byte next to it in UTF-16.
+func sniffUTF16(body []byte) (encoding.Encoding, bool) {
+	if len(body) < 2 {
+		return nil, false
+	}
+	switch {
+	case body[0] == '<' && body[1] == 0:
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), true
+	case body[0] == 0 && body[1] == '<':
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), true
+	}
+	return nil, false
+}
+
+// Decodes the body into a UTF-8 string. The encoding is detected from the
+// byte order mark, the Content-Type header and <meta> tags in the html (in that
+// order), falling back to UTF-16 (if it looks like it), UTF-8 or
+// Windows-1252.
+func toUTF8(body []byte, contentType string) (string, error) {
+	encoding, name, certain := charset.DetermineEncoding(body, contentType)
+	if !certain {
+		if utf16, ok := sniffUTF16(body); ok {
+			encoding, name = utf16, "utf-16"
+		}
+	}
+
+	if name == "utf-8" {
+		body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+		if utf8.Valid(body) {
+			return string(body), nil
+		}
+		return strings.ToValidUTF8(string(body), "�"), nil
+	}
+
+	decoded, err := encoding.NewDecoder().Bytes(body)
+	if err != nil {
+		return "", err
+	}
+	// The UTF-16 decoders keep the byte order mark
+	return strings.TrimPrefix(string(decoded), "\ufeff"), nil
+}
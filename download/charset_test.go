@@ -0,0 +1,117 @@
+package download
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func encode(t *testing.T, enc encoding.Encoding, text string) []byte {
+	t.Helper()
+	encoded, err := enc.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatalf("unable to encode the fixture: %v", err)
+	}
+	return encoded
+}
+
+func TestToUTF8(t *testing.T) {
+	const germanText = "Grüße aus Österreich"
+	const japaneseText = "日本語のページ"
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{
+			name: "utf-8 without declaration",
+			body: []byte("<html><body>" + germanText + "</body></html>"),
+			want: germanText,
+		},
+		{
+			name:        "header charset",
+			body:        encode(t, charmap.ISO8859_1, "<html><body>"+germanText+"</body></html>"),
+			contentType: "text/html; charset=iso-8859-1",
+			want:        germanText,
+		},
+		{
+			name:        "header charset wins over meta",
+			body:        encode(t, charmap.ISO8859_1, `<html><head><meta charset="utf-8"></head><body>`+germanText+"</body></html>"),
+			contentType: "text/html; charset=iso-8859-1",
+			want:        germanText,
+		},
+		{
+			name: "meta charset",
+			body: encode(t, japanese.ShiftJIS, `<html><head><meta charset="shift_jis"></head><body>`+japaneseText+"</body></html>"),
+			want: japaneseText,
+		},
+		{
+			name: "meta http-equiv",
+			body: encode(t, japanese.ShiftJIS, `<html><head><meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS"></head><body>`+japaneseText+"</body></html>"),
+			want: japaneseText,
+		},
+		{
+			name:        "shift_jis header",
+			body:        encode(t, japanese.ShiftJIS, "<html><body>"+japaneseText+"</body></html>"),
+			contentType: "text/html; charset=Shift_JIS",
+			want:        japaneseText,
+		},
+		{
+			name: "utf-8 bom",
+			body: []byte("\xef\xbb\xbf<html><body>" + germanText + "</body></html>"),
+			want: germanText,
+		},
+		{
+			name:        "bom wins over header",
+			body:        encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), "<html><body>"+japaneseText+"</body></html>"),
+			contentType: "text/html; charset=iso-8859-1",
+			want:        japaneseText,
+		},
+		{
+			name: "utf-16 big endian bom",
+			body: encode(t, unicode.UTF16(unicode.BigEndian, unicode.UseBOM), "<html><body>"+germanText+"</body></html>"),
+			want: germanText,
+		},
+		{
+			name: "sniffed windows-1252",
+			body: encode(t, charmap.Windows1252, "<html><body>"+germanText+" – 5 €</body></html>"),
+			want: germanText + " – 5 €",
+		},
+		{
+			name: "sniffed utf-16 little endian",
+			body: encode(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), "<html><body>"+japaneseText+"</body></html>"),
+			want: japaneseText,
+		},
+		{
+			name: "sniffed utf-16 big endian",
+			body: encode(t, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), "<html><body>"+germanText+"</body></html>"),
+			want: germanText,
+		},
+		{
+			name: "invalid utf-8 is replaced",
+			body: []byte(`<html><head><meta charset="utf-8"></head><body>a` + "\xff" + `b</body></html>`),
+			want: "a�b",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := toUTF8(test.body, test.contentType)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(got, test.want) {
+				t.Errorf("got %q, want it to contain %q", got, test.want)
+			}
+			if strings.HasPrefix(got, "\ufeff") {
+				t.Errorf("the byte order mark wasn't removed from %q", got)
+			}
+		})
+	}
+}
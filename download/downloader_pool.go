@@ -1,31 +1,55 @@
 package download
 
 import (
+	"errors"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"sync"
 	"time"
 
+	"github.com/flofriday/websearch/frontier"
 	"github.com/flofriday/websearch/model"
 	"github.com/flofriday/websearch/queue"
+	"github.com/flofriday/websearch/robots"
+	"github.com/flofriday/websearch/stats"
+	"github.com/flofriday/websearch/store"
 )
 
+const USER_AGENT = "websearch/1.0 (+https://github.com/flofriday/websearch)"
+
 type DownloaderPool struct {
-	requestQueue  queue.Queue[*model.Request]
+	requestQueue  *frontier.Frontier
 	responseQueue queue.Queue[*model.Response]
+	robots        *robots.Cache
+	crawlStore    store.CrawlStore
+	work          *sync.WaitGroup
+	transport     *http.Transport
+	maxBodySize   int64
+	failures      stats.Counter[Reason]
 	workerCount   int
 }
 
 func NewDownloaderPool(
-	requestQueue queue.Queue[*model.Request],
+	requestQueue *frontier.Frontier,
 	responseQueue queue.Queue[*model.Response],
+	robots *robots.Cache,
+	crawlStore store.CrawlStore,
+	work *sync.WaitGroup,
+	guard *AddressGuard,
+	maxBodySize int64,
 	workerCount int,
 ) *DownloaderPool {
 	return &DownloaderPool{
 		requestQueue:  requestQueue,
 		responseQueue: responseQueue,
+		robots:        robots,
+		crawlStore:    crawlStore,
+		work:          work,
+		transport:     NewTransport(guard),
+		maxBodySize:   maxBodySize,
 		workerCount:   workerCount,
 	}
 }
@@ -48,12 +72,21 @@ func (p *DownloaderPool) newClient() *http.Client {
 	// FIXME: We should somehow tell the curator that we had a redirect and not
 	// issue this final URL again.
 	// Also maybe we already have downloaded the final destination.
+
+	// All workers share the transport (and therefore the connection pool and
+	// the address guard) but need their own client for the redirect handling.
 	return &http.Client{
-		Jar:     nil,
-		Timeout: 5 * time.Second,
+		Jar:       nil,
+		Transport: p.transport,
+		Timeout:   5 * time.Second,
 	}
 }
 
+// A summary of why downloads failed, meant for the status log.
+func (p *DownloaderPool) Failures() string {
+	return p.failures.String()
+}
+
 func (p *DownloaderPool) downloadLoop() {
 	client := p.newClient()
 
@@ -63,35 +96,129 @@ func (p *DownloaderPool) downloadLoop() {
 			break
 		}
 
-		redirects := []*url.URL{}
-		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-			for _, req := range via {
-				redirects = append(redirects, req.URL)
+		response, err := p.download(client, request)
+		p.requestQueue.Done(request)
+		if err != nil {
+			var downloadErr *DownloadError
+			if errors.As(err, &downloadErr) {
+				p.failures.Add(downloadErr.Reason)
 			}
-			return nil
+			log.Printf("WARNING: Could not download %v\n", err)
+			p.markFailed(request)
+			p.work.Done()
+			continue
 		}
+		p.responseQueue.Put(response)
+	}
+}
 
-		// FIXME: Security-wise we must dissallow any requests that are to our
-		// local network
-		resp, err := client.Get(request.Url.String())
-		if err != nil {
-			log.Printf("WARNING: Could not download: %v\n", request.Url.String())
-			continue
+// Remembers that the download failed, so a resumed crawl doesn't request the
+// url again.
+func (p *DownloaderPool) markFailed(request *model.Request) {
+	err := p.crawlStore.PutURL(&store.CrawlURL{
+		Url:   request.Url.String(),
+		Index: request.Index,
+		Depth: request.Depth,
+		State: store.URL_FAILED,
+	})
+	if err != nil {
+		log.Printf("WARNING: Unable to store the crawl state of %v because '%v'", request.Url.String(), err)
+	}
+}
+
+// Checks whether the media type is a html document we can index.
+func isHTML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+func (p *DownloaderPool) download(client *http.Client, request *model.Request) (*model.Response, error) {
+	allowed, err := p.robots.Allowed(request.Url)
+	if errors.Is(err, ErrForbiddenAddress) {
+		return nil, newDownloadError(request.Url, REASON_FORBIDDEN, "%v", err)
+	}
+	if err != nil {
+		return nil, newDownloadError(request.Url, REASON_ROBOTS, "robots.txt unavailable '%v'", err)
+	}
+	if !allowed {
+		return nil, newDownloadError(request.Url, REASON_ROBOTS, "disallowed by robots.txt")
+	}
+
+	redirects := []*url.URL{}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		// Redirects can lead us to pages the site doesn't want us to see
+		allowed, err := p.robots.Allowed(req.URL)
+		if errors.Is(err, ErrForbiddenAddress) {
+			return newDownloadError(req.URL, REASON_FORBIDDEN, "redirect to %v", err)
 		}
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			log.Printf("WARNING: Could not download: %v\n", request.Url.String())
-			continue
+		if !allowed {
+			return newDownloadError(req.URL, REASON_ROBOTS, "redirect disallowed by robots.txt")
+		}
+		for _, req := range via {
+			redirects = append(redirects, req.URL)
+		}
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, request.Url.String(), nil)
+	if err != nil {
+		return nil, newDownloadError(request.Url, REASON_NETWORK, "%v", err)
+	}
+	req.Header.Set("User-Agent", USER_AGENT)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := client.Do(req)
+	if err != nil {
+		var downloadErr *DownloadError
+		if errors.As(err, &downloadErr) {
+			return nil, downloadErr
+		}
+		if errors.Is(err, ErrForbiddenAddress) {
+			return nil, newDownloadError(request.Url, REASON_FORBIDDEN, "%v", err)
 		}
+		return nil, newDownloadError(request.Url, REASON_NETWORK, "%v", err)
+	}
+	defer resp.Body.Close()
 
-		// FIXME: can this fail, if it is not valid utf-8?
-		content := string(body)
-		p.responseQueue.Put(&model.Response{
-			Index:      request.Index,
-			Url:        resp.Request.URL,
-			Content:    content,
-			Redirected: redirects,
-		})
+	// Abort as early as possible, before reading the body
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newDownloadError(request.Url, REASON_STATUS, "status %v", resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !isHTML(contentType) {
+		return nil, newDownloadError(request.Url, REASON_CONTENT_TYPE, "content type '%v'", contentType)
 	}
+	if resp.ContentLength > p.maxBodySize {
+		return nil, newDownloadError(request.Url, REASON_TOO_LARGE, "content length %v bytes", resp.ContentLength)
+	}
+
+	// The content length can be missing or lie, so we also limit the reader
+	body, err := io.ReadAll(io.LimitReader(resp.Body, p.maxBodySize+1))
+	if err != nil {
+		return nil, newDownloadError(request.Url, REASON_NETWORK, "%v", err)
+	}
+	if int64(len(body)) > p.maxBodySize {
+		return nil, newDownloadError(request.Url, REASON_TOO_LARGE, "body larger than %v bytes", p.maxBodySize)
+	}
+
+	// Without a content type header we have to guess
+	if contentType == "" && !isHTML(http.DetectContentType(body)) {
+		return nil, newDownloadError(request.Url, REASON_CONTENT_TYPE, "sniffed content type '%v'", http.DetectContentType(body))
+	}
+
+	content, err := toUTF8(body, contentType)
+	if err != nil {
+		return nil, newDownloadError(request.Url, REASON_ENCODING, "%v", err)
+	}
+
+	return &model.Response{
+		Index:           request.Index,
+		Url:             resp.Request.URL,
+		Content:         content,
+		Redirected:      redirects,
+		Depth:           request.Depth,
+		ContentLanguage: resp.Header.Get("Content-Language"),
+	}, nil
 }
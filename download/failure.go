@@ -0,0 +1,39 @@
+package download
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Why a download did not produce a response.
+type Reason string
+
+const (
+	REASON_ROBOTS       Reason = "robots"
+	REASON_FORBIDDEN    Reason = "forbidden-address"
+	REASON_NETWORK      Reason = "network"
+	REASON_STATUS       Reason = "status"
+	REASON_CONTENT_TYPE Reason = "content-type"
+	REASON_TOO_LARGE    Reason = "too-large"
+	REASON_ENCODING     Reason = "encoding"
+)
+
+// The error a download failed with. Reason can be used to categorize and
+// count the failures while Detail is meant for humans.
+type DownloadError struct {
+	Url    *url.URL
+	Reason Reason
+	Detail string
+}
+
+func (e *DownloadError) Error() string {
+	return fmt.Sprintf("%v: %v (%v)", e.Url.String(), e.Detail, e.Reason)
+}
+
+func newDownloadError(link *url.URL, reason Reason, format string, a ...any) *DownloadError {
+	return &DownloadError{
+		Url:    link,
+		Reason: reason,
+		Detail: fmt.Sprintf(format, a...),
+	}
+}
@@ -0,0 +1,123 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+var ErrForbiddenAddress = errors.New("forbidden address")
+
+// Ranges that are not covered by the net.IP helpers but still must never be
+// reached from the crawler.
+var forbiddenNets = mustParseCIDRs(
+	"0.0.0.0/8",       // "This" network
+	"100.64.0.0/10",   // Carrier-grade NAT
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // Documentation
+	"198.18.0.0/15",   // Benchmarking
+	"198.51.100.0/24", // Documentation
+	"203.0.113.0/24",  // Documentation
+	"240.0.0.0/4",     // Reserved and broadcast
+	"64:ff9b::/96",    // NAT64, could embed a private IPv4
+	"2001:db8::/32",   // Documentation
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := []*net.IPNet{}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// The AddressGuard prevents the crawler from connecting to private, loopback,
+// link-local and multicast addresses. Otherwise any page could make us request
+// our own network (SSRF).
+//
+// The check happens when the connection is established, after the hostname
+// was resolved, so it also covers redirects and DNS rebinding.
+type AddressGuard struct {
+	allowed []*net.IPNet
+}
+
+// Networks in allowed are reachable even if they would otherwise be forbidden,
+// which is useful for tests or crawling an intranet.
+func NewAddressGuard(allowed []*net.IPNet) *AddressGuard {
+	return &AddressGuard{
+		allowed: allowed,
+	}
+}
+
+// Checks whether we are allowed to connect to the ip.
+func (g *AddressGuard) Check(ip net.IP) error {
+	for _, allowed := range g.allowed {
+		if allowed.Contains(ip) {
+			return nil
+		}
+	}
+
+	if ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() {
+		return fmt.Errorf("%w: %v", ErrForbiddenAddress, ip)
+	}
+
+	for _, forbidden := range forbiddenNets {
+		if forbidden.Contains(ip) {
+			return fmt.Errorf("%w: %v", ErrForbiddenAddress, ip)
+		}
+	}
+	return nil
+}
+
+func (g *AddressGuard) control(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: %v is not an ip", ErrForbiddenAddress, host)
+	}
+	return g.Check(ip)
+}
+
+// Creates a http transport whose connections are checked by the guard.
+func NewTransport(guard *AddressGuard) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: guard.control,
+	}
+
+	return &http.Transport{
+		// No proxy, because then we would only check the address of the proxy
+		Proxy:               nil,
+		DialContext:         dialer.DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 5 * time.Second,
+	}
+}
+
+// Creates a http client whose connections are checked by the guard.
+func NewClient(guard *AddressGuard) *http.Client {
+	return &http.Client{
+		Jar:       nil,
+		Transport: NewTransport(guard),
+		Timeout:   5 * time.Second,
+	}
+}
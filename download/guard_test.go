@@ -0,0 +1,97 @@
+package download
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/flofriday/websearch/model"
+	"github.com/flofriday/websearch/robots"
+)
+
+func TestAddressGuard(t *testing.T) {
+	guard := NewAddressGuard(mustParseCIDRs("10.1.0.0/16"))
+
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"10.0.0.1", false},
+		{"192.168.1.1", false},
+		{"172.16.0.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"64:ff9b::a00:1", false},
+		// Allowlisted
+		{"10.1.2.3", true},
+		{"::ffff:10.1.2.3", true},
+	}
+
+	for _, test := range tests {
+		err := guard.Check(net.ParseIP(test.ip))
+		if (err == nil) != test.allowed {
+			t.Errorf("Check(%v) = %v, want allowed %v", test.ip, err, test.allowed)
+		}
+		if err != nil && !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("Check(%v) = %v, want ErrForbiddenAddress", test.ip, err)
+		}
+	}
+}
+
+func TestRedirectToLoopbackIsForbidden(t *testing.T) {
+	// Only the address of the server is allowed, so it stands in for a public
+	// site. Everything else in 127.0.0.0/8 is still loopback and the guard
+	// refuses to connect before the port even matters.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, "http://127.0.0.2:8080/admin", http.StatusFound)
+	}))
+	defer server.Close()
+
+	guard := NewAddressGuard(mustParseCIDRs("127.0.0.1/32"))
+	pool := NewDownloaderPool(nil, nil, robots.NewCache(NewClient(guard), USER_AGENT), nil, nil, guard, 1024, 1)
+
+	link, _ := url.Parse(server.URL + "/")
+	_, err := pool.download(pool.newClient(), &model.Request{Url: link})
+
+	var downloadErr *DownloadError
+	if !errors.As(err, &downloadErr) || downloadErr.Reason != REASON_FORBIDDEN {
+		t.Errorf("expected the redirect to be refused as %v, got %v", REASON_FORBIDDEN, err)
+	}
+}
+
+func TestDirectLoopbackIsForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	guard := NewAddressGuard(nil)
+	pool := NewDownloaderPool(nil, nil, robots.NewCache(NewClient(guard), USER_AGENT), nil, nil, guard, 1024, 1)
+
+	link, _ := url.Parse(server.URL + "/")
+	_, err := pool.download(pool.newClient(), &model.Request{Url: link})
+
+	var downloadErr *DownloadError
+	if !errors.As(err, &downloadErr) || downloadErr.Reason != REASON_FORBIDDEN {
+		t.Errorf("expected the request to be refused as %v, got %v", REASON_FORBIDDEN, err)
+	}
+}
@@ -38,3 +38,13 @@ func All[T any](a []T, f func(T) bool) bool {
 	}
 	return true
 }
+
+func Filter[T any](a []T, f func(T) bool) []T {
+	l := []T{}
+	for _, e := range a {
+		if f(e) {
+			l = append(l, e)
+		}
+	}
+	return l
+}
@@ -0,0 +1,206 @@
+// A host-aware queue of requests, that sits between the curator and the
+// downloaders and makes sure we don't overwhelm any single host.
+package frontier
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/flofriday/websearch/model"
+	"github.com/flofriday/websearch/robots"
+)
+
+// Some sites ask for absurd crawl delays, we cap them so that a single host
+// cannot stall the end of a crawl forever.
+const MAX_CRAWL_DELAY = 30 * time.Second
+
+type hostState struct {
+	pending  []*model.Request
+	inFlight int
+	delay    time.Duration
+	next     time.Time
+	active   bool
+}
+
+// The Frontier implements queue.Queue[*model.Request] but only hands out a
+// request once its host is ready for it. A host is ready when it has fewer
+// than maxInFlight requests running and the delay since the last request to
+// it has passed. The delay is the larger one of minDelay and the Crawl-delay
+// from the robots.txt of the host.
+//
+// Downloaders must call Done for every request they got from the frontier
+// once they are finished with it.
+type Frontier struct {
+	robots      *robots.Cache
+	maxInFlight int
+	minDelay    time.Duration
+
+	hosts  map[string]*hostState
+	active []*hostState
+	cursor int
+	size   int64
+	closed bool
+	lock   sync.Mutex
+	cond   *sync.Cond
+}
+
+func NewFrontier(robots *robots.Cache, maxInFlight int, minDelay time.Duration) *Frontier {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+
+	f := &Frontier{
+		robots:      robots,
+		maxInFlight: maxInFlight,
+		minDelay:    minDelay,
+		hosts:       map[string]*hostState{},
+	}
+	f.cond = sync.NewCond(&f.lock)
+	return f
+}
+
+func (f *Frontier) host(name string) *hostState {
+	host, ok := f.hosts[name]
+	if !ok {
+		host = &hostState{delay: f.minDelay}
+		f.hosts[name] = host
+	}
+	return host
+}
+
+func (f *Frontier) Put(request *model.Request) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return errors.New("Frontier closed")
+	}
+
+	host := f.host(request.Url.Host)
+	host.pending = append(host.pending, request)
+	if !host.active {
+		host.active = true
+		f.active = append(f.active, host)
+	}
+	f.size++
+	f.cond.Broadcast()
+	return nil
+}
+
+// Returns the next request whose host is ready. Blocks until there is such a
+// request or the frontier is closed and empty.
+func (f *Frontier) Get() (*model.Request, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for {
+		request, wait := f.pop(time.Now())
+		if request != nil {
+			return request, nil
+		}
+
+		if f.closed && f.size == 0 {
+			return nil, errors.New("Frontier closed")
+		}
+
+		// Either all hosts are busy (Done will wake us up) or we need to wait
+		// for the delay of a host to pass.
+		if wait > 0 {
+			timer := time.AfterFunc(wait, func() {
+				f.lock.Lock()
+				f.cond.Broadcast()
+				f.lock.Unlock()
+			})
+			f.cond.Wait()
+			timer.Stop()
+		} else {
+			f.cond.Wait()
+		}
+	}
+}
+
+// Pops a request from the next ready host in a round-robin fashion. If no
+// host is ready it returns how long until the first host becomes ready,
+// or zero if all hosts are waiting for requests to finish.
+func (f *Frontier) pop(now time.Time) (*model.Request, time.Duration) {
+	var wait time.Duration
+	for i := 0; i < len(f.active); i++ {
+		idx := (f.cursor + i) % len(f.active)
+		host := f.active[idx]
+
+		if host.inFlight >= f.maxInFlight {
+			continue
+		}
+		if now.Before(host.next) {
+			if d := host.next.Sub(now); wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+
+		request := host.pending[0]
+		host.pending[0] = nil
+		host.pending = host.pending[1:]
+		host.inFlight++
+		host.next = now.Add(host.delay)
+		f.size--
+
+		if len(host.pending) == 0 {
+			host.active = false
+			f.active = append(f.active[:idx], f.active[idx+1:]...)
+			f.cursor = idx
+		} else {
+			f.cursor = idx + 1
+		}
+		if len(f.active) > 0 {
+			f.cursor %= len(f.active)
+		} else {
+			f.cursor = 0
+		}
+		return request, 0
+	}
+	return nil, wait
+}
+
+// Marks a request as finished, so its host can receive the next one.
+func (f *Frontier) Done(request *model.Request) {
+	// By now the downloader has consulted the robots.txt so this only blocks
+	// on the network if a failed fetch is retried.
+	delay := f.minDelay
+	if f.robots != nil {
+		crawlDelay := f.robots.CrawlDelay(request.Url)
+		if crawlDelay > MAX_CRAWL_DELAY {
+			crawlDelay = MAX_CRAWL_DELAY
+		}
+		if crawlDelay > delay {
+			delay = crawlDelay
+		}
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	host := f.host(request.Url.Host)
+	if host.inFlight > 0 {
+		host.inFlight--
+	}
+	if delay > host.delay {
+		host.next = host.next.Add(delay - host.delay)
+		host.delay = delay
+	}
+	f.cond.Broadcast()
+}
+
+func (f *Frontier) Size() (int64, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.size, nil
+}
+
+func (f *Frontier) Close() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.closed = true
+	f.cond.Broadcast()
+}
@@ -0,0 +1,210 @@
+package frontier
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/flofriday/websearch/model"
+	"github.com/flofriday/websearch/robots"
+)
+
+func request(t *testing.T, link string) *model.Request {
+	t.Helper()
+	uri, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &model.Request{Url: uri}
+}
+
+// Gets the next request in the background, so tests can check whether it
+// blocks.
+func getAsync(f *Frontier) <-chan *model.Request {
+	result := make(chan *model.Request, 1)
+	go func() {
+		request, err := f.Get()
+		if err != nil {
+			request = nil
+		}
+		result <- request
+	}()
+	return result
+}
+
+func mustGet(t *testing.T, f *Frontier) *model.Request {
+	t.Helper()
+	select {
+	case request := <-getAsync(f):
+		if request == nil {
+			t.Fatal("expected a request, the frontier is closed")
+		}
+		return request
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a request, Get blocked")
+	}
+	return nil
+}
+
+func TestInFlightCap(t *testing.T) {
+	f := NewFrontier(nil, 2, 0)
+	for _, link := range []string{"https://a.com/1", "https://a.com/2", "https://a.com/3"} {
+		f.Put(request(t, link))
+	}
+
+	first := mustGet(t, f)
+	mustGet(t, f)
+
+	// Both slots of the host are taken
+	third := getAsync(f)
+	select {
+	case request := <-third:
+		t.Fatalf("expected Get to block, got %v", request.Url)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	f.Done(first)
+	select {
+	case request := <-third:
+		if request == nil || request.Url.Path != "/3" {
+			t.Errorf("expected the third request, got %v", request)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Done to free a slot")
+	}
+}
+
+func TestOtherHostsArentBlocked(t *testing.T) {
+	f := NewFrontier(nil, 1, time.Hour)
+	f.Put(request(t, "https://a.com/1"))
+	f.Put(request(t, "https://a.com/2"))
+	f.Put(request(t, "https://b.com/1"))
+
+	if got := mustGet(t, f); got.Url.Host != "a.com" {
+		t.Errorf("expected a.com first, got %v", got.Url)
+	}
+	if got := mustGet(t, f); got.Url.Host != "b.com" {
+		t.Errorf("expected b.com while a.com is busy, got %v", got.Url)
+	}
+}
+
+func TestMinDelay(t *testing.T) {
+	delay := 200 * time.Millisecond
+	f := NewFrontier(nil, 10, delay)
+	f.Put(request(t, "https://a.com/1"))
+	f.Put(request(t, "https://a.com/2"))
+
+	start := time.Now()
+	f.Done(mustGet(t, f))
+	mustGet(t, f)
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("expected the second request after %v, got it after %v", delay, elapsed)
+	}
+}
+
+func TestCrawlDelayFromRobots(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nCrawl-delay: 0.3\n"))
+	}))
+	defer server.Close()
+
+	delay := 300 * time.Millisecond
+	f := NewFrontier(robots.NewCache(server.Client(), "websearch/1.0"), 10, 0)
+	f.Put(request(t, server.URL+"/1"))
+	f.Put(request(t, server.URL+"/2"))
+
+	// Until the first request is done the frontier doesn't know the delay
+	start := time.Now()
+	f.Done(mustGet(t, f))
+	mustGet(t, f)
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("expected the second request after %v, got it after %v", delay, elapsed)
+	}
+}
+
+func TestCrawlDelayIsCapped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nCrawl-delay: 86400\n"))
+	}))
+	defer server.Close()
+
+	f := NewFrontier(robots.NewCache(server.Client(), "websearch/1.0"), 10, 0)
+	req := request(t, server.URL+"/1")
+	f.Put(req)
+	f.Done(mustGet(t, f))
+
+	host := f.hosts[req.Url.Host]
+	if host.delay != MAX_CRAWL_DELAY {
+		t.Errorf("expected the delay to be capped at %v, got %v", MAX_CRAWL_DELAY, host.delay)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	f := NewFrontier(nil, 10, 0)
+	for _, path := range []string{"/1", "/2"} {
+		for _, host := range []string{"a.com", "b.com", "c.com"} {
+			f.Put(request(t, "https://"+host+path))
+		}
+	}
+
+	want := []string{"a.com/1", "b.com/1", "c.com/1", "a.com/2", "b.com/2", "c.com/2"}
+	for _, w := range want {
+		got := mustGet(t, f)
+		if got.Url.Host+got.Url.Path != w {
+			t.Errorf("expected %v, got %v", w, got.Url.Host+got.Url.Path)
+		}
+	}
+}
+
+func TestGetAfterClose(t *testing.T) {
+	f := NewFrontier(nil, 1, 0)
+	f.Put(request(t, "https://a.com/1"))
+	f.Put(request(t, "https://a.com/2"))
+
+	// A waiting Get is woken up by Close
+	first := mustGet(t, f)
+	blocked := getAsync(f)
+	f.Close()
+	if err := f.Put(request(t, "https://a.com/3")); err == nil {
+		t.Errorf("expected Put to fail after Close")
+	}
+
+	// The remaining requests are still handed out
+	f.Done(first)
+	select {
+	case request := <-blocked:
+		if request == nil || request.Url.Path != "/2" {
+			t.Errorf("expected the second request, got %v", request)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the second request after Close")
+	}
+
+	// Once empty, Get returns right away
+	select {
+	case request := <-getAsync(f):
+		if request != nil {
+			t.Errorf("expected no request, got %v", request.Url)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Get to return once closed and empty")
+	}
+}
+
+func TestCloseWakesUpEmptyGet(t *testing.T) {
+	f := NewFrontier(nil, 1, 0)
+	blocked := getAsync(f)
+	time.Sleep(50 * time.Millisecond)
+	f.Close()
+
+	select {
+	case request := <-blocked:
+		if request != nil {
+			t.Errorf("expected no request, got %v", request.Url)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Close to wake up Get")
+	}
+}
@@ -5,13 +5,13 @@ go 1.20
 require (
 	github.com/mattn/go-sqlite3 v1.14.17
 	github.com/urfave/cli/v2 v2.25.5
+	golang.org/x/net v0.8.0
 	golang.org/x/text v0.9.0
 )
 
 require (
 	github.com/antchfx/xpath v1.2.3 // indirect
 	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
-	golang.org/x/net v0.8.0 // indirect
 )
 
 require (
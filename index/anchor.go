@@ -0,0 +1,51 @@
+package index
+
+import (
+	"github.com/flofriday/websearch/query"
+	"github.com/flofriday/websearch/store"
+)
+
+// The distance between the texts of two links, so that a phrase never
+// matches across them.
+const ANCHOR_GAP = 100
+
+// Indexes the text of the links pointing to a document as part of that
+// document. Other pages often describe a page better than the page itself,
+// but we only know all of them once the crawl is finished, so the whole field
+// is rebuilt every time.
+func BuildAnchorIndex(linkStore store.LinkStore, indexStore store.IndexStore) (int, error) {
+	anchors, err := linkStore.GetAnchors()
+	if err != nil {
+		return 0, err
+	}
+
+	// The anchor text is analyzed in the language of the target
+	languages, err := indexStore.GetLanguages()
+	if err != nil {
+		return 0, err
+	}
+
+	documents := make(map[int64]map[string][]int, len(anchors))
+	for index, texts := range anchors {
+		analyzer := query.AnalyzerFor(languages[index])
+		positions := map[string][]int{}
+		offset := 0
+		for _, text := range texts {
+			tokens := query.TokenizeSpans(text)
+			for _, token := range analyzer.Filter(tokens) {
+				positions[token.Term] = append(positions[token.Term], offset+token.Position)
+			}
+			offset += len(tokens) + ANCHOR_GAP
+		}
+		if len(positions) == 0 {
+			continue
+		}
+		documents[index] = positions
+	}
+
+	err = indexStore.ReplaceField(store.FIELD_ANCHOR, documents)
+	if err != nil {
+		return 0, err
+	}
+	return len(documents), nil
+}
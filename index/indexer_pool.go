@@ -3,11 +3,13 @@ package index
 import (
 	"log"
 	"net/url"
+	"path"
 	"strings"
 	"sync"
 
 	"github.com/antchfx/htmlquery"
 
+	"github.com/flofriday/websearch/curate"
 	"github.com/flofriday/websearch/fp"
 	"github.com/flofriday/websearch/model"
 	"github.com/flofriday/websearch/query"
@@ -17,28 +19,71 @@ import (
 
 const DESCRIPTION_LEN = 200
 
+// The longest body text that is stored for snippets, in bytes
+const MAX_BODY_LEN = 100 * 1024
+
 type IndexerPool struct {
-	discoverQueue queue.Queue[*url.URL]
-	documentQueue queue.Queue[*model.Response]
-	documentStore store.DocumentStore
-	indexStore    store.IndexStore
-	workerCount   int
+	discoverQueue   queue.Queue[*model.Link]
+	documentQueue   queue.Queue[*model.Response]
+	documentStore   store.DocumentStore
+	indexStore      store.IndexStore
+	linkStore       store.LinkStore
+	suggestionStore store.SuggestionStore
+	crawlStore      store.CrawlStore
+	work            *sync.WaitGroup
+	fingerprints    *fingerprintIndex
+	workerCount     int
 }
 
 func NewIndexerPool(
-	discoverQueue queue.Queue[*url.URL],
+	discoverQueue queue.Queue[*model.Link],
 	documentQueue queue.Queue[*model.Response],
 	documentStore store.DocumentStore,
 	indexStore store.IndexStore,
+	linkStore store.LinkStore,
+	suggestionStore store.SuggestionStore,
+	crawlStore store.CrawlStore,
+	work *sync.WaitGroup,
+	maxDuplicateDistance int,
 	workerCount int,
 ) *IndexerPool {
-	return &IndexerPool{
-		discoverQueue: discoverQueue,
-		documentQueue: documentQueue,
-		documentStore: documentStore,
-		indexStore:    indexStore,
-		workerCount:   workerCount,
+	pool := &IndexerPool{
+		discoverQueue:   discoverQueue,
+		documentQueue:   documentQueue,
+		documentStore:   documentStore,
+		indexStore:      indexStore,
+		linkStore:       linkStore,
+		suggestionStore: suggestionStore,
+		crawlStore:      crawlStore,
+		work:            work,
+		workerCount:     workerCount,
+	}
+	if maxDuplicateDistance >= 0 {
+		pool.fingerprints = newFingerprintIndex(maxDuplicateDistance)
 	}
+	return pool
+}
+
+// Loads the fingerprints of the documents already in the store, so that we
+// also detect duplicates of them.
+// Must be called before Run.
+func (p *IndexerPool) LoadFingerprints() error {
+	if p.fingerprints == nil {
+		return nil
+	}
+
+	indexes, fingerprints, err := p.documentStore.GetFingerprints()
+	if err != nil {
+		return err
+	}
+	for i, index := range indexes {
+		// Documents without a body have no fingerprint
+		if fingerprints[i] == 0 {
+			continue
+		}
+		p.fingerprints.addUnique(index, fingerprints[i])
+	}
+	return nil
 }
 
 func (p *IndexerPool) Run() {
@@ -61,49 +106,147 @@ func (p *IndexerPool) indexLoop() {
 		if err != nil {
 			break
 		}
+		p.index(response)
+		p.work.Done()
+	}
+}
 
-		document, words, links, err := parseHTML(response.Content, response.Url)
-		if err != nil {
-			log.Printf("WARNING: could not parse the following document %v because %v", document.Url.String(), err.Error())
-			continue
+// Stores and indexes the document and passes its links on to the curator.
+func (p *IndexerPool) index(response *model.Response) {
+	document, htmlLang, fields, links, err := parseHTML(response.Content, response.Url)
+	if err != nil {
+		log.Printf("WARNING: could not parse the following document %v because %v", response.Url.String(), err.Error())
+		p.markCrawled(response, response.Index, store.URL_FAILED)
+		return
+	}
+
+	document.Index = response.Index
+	if response.Canonical != nil {
+		document.Url = response.Canonical
+	}
+
+	// Every language has its own stopwords and stemming
+	document.Language = detectLanguage(htmlLang, response.ContentLanguage, fields[store.FIELD_BODY])
+	analyzer := query.AnalyzerFor(document.Language)
+	tokens := map[int][]query.Token{}
+	for field, text := range fields {
+		tokens[field] = analyzer.Analyze(text)
+	}
+
+	// The same content is often served under many urls (print views,
+	// session ids, mirrors), we only want it once. Pages without text,
+	// like image galleries, have nothing to compare.
+	document.Fingerprint = simHash(fp.Map(tokens[store.FIELD_BODY], func(t query.Token) string { return t.Term }))
+	if p.fingerprints != nil && len(tokens[store.FIELD_BODY]) > 0 {
+		if original, ok := p.fingerprints.addUnique(document.Index, document.Fingerprint); !ok {
+			log.Printf("INFO: Skipping %v, near-duplicate of document %v\n", document.Url.String(), original)
+			// Links to the duplicate count for the document we kept
+			p.markCrawled(response, original, store.URL_INDEXED)
+			return
 		}
+	}
 
-		document.Index = response.Index
-		err = p.documentStore.Put(document)
-		if err != nil {
-			log.Printf("WARNING: Unable to store doc %v because '%v'", document, err.Error())
-			continue
+	err = p.documentStore.Put(document)
+	if err != nil {
+		log.Printf("WARNING: Unable to store doc %v because '%v'", document, err.Error())
+		return
+	}
+	p.markCrawled(response, document.Index, store.URL_INDEXED)
+
+	targets := map[string][]string{}
+	discovered := []*store.CrawlURL{}
+	for _, link := range links {
+		link.Depth = response.Depth + 1
+		target := curate.Canonicalize(link.Url).String()
+		if _, ok := targets[target]; !ok {
+			discovered = append(discovered, &store.CrawlURL{Url: target, Index: -1, Depth: link.Depth, State: store.URL_DISCOVERED})
 		}
+		targets[target] = append(targets[target], link.Text)
+	}
+
+	// The links are stored before they are queued, so that a resumed
+	// crawl still knows them if this one is interrupted
+	err = p.crawlStore.PutURLs(discovered)
+	if err != nil {
+		log.Printf("WARNING: Unable to store the discovered links of doc %v because '%v'", document.Url.String(), err.Error())
+	}
+	p.work.Add(len(links))
+	for _, link := range links {
+		p.discoverQueue.Put(link)
+	}
+
+	// Remember the link graph for PageRank and the anchor texts
+	targetList := make([]string, 0, len(targets))
+	textList := make([]string, 0, len(targets))
+	for target, texts := range targets {
+		targetList = append(targetList, target)
+		textList = append(textList, strings.TrimSpace(strings.Join(texts, " ")))
+	}
+	err = p.linkStore.PutLinks(document.Index, targetList, textList)
+	if err != nil {
+		log.Printf("WARNING: Unable to store the links of doc %v because '%v'", document.Url.String(), err.Error())
+	}
 
-		for _, link := range links {
-			p.discoverQueue.Put(link)
+	positions := map[int]map[string][]int{}
+	for field, fieldTokens := range tokens {
+		if len(fieldTokens) > 0 {
+			positions[field] = termPositions(fieldTokens)
 		}
+	}
+	err = p.indexStore.PutAllWords(document.Index, document.Language, positions)
+	if err != nil {
+		log.Printf("WARNING: Unable to index doc %v because '%v'", document.Url.String(), err.Error())
+	}
+
+	// The words are suggested as they were written, not their stems
+	terms := query.SuggestionTerms(analyzer, fields[store.FIELD_TITLE], fields[store.FIELD_HEADING], fields[store.FIELD_BODY])
+	err = p.suggestionStore.PutTerms(terms)
+	if err != nil {
+		log.Printf("WARNING: Unable to store the terms of doc %v because '%v'", document.Url.String(), err.Error())
+	}
+}
+
+// Remembers the state of the urls the response was downloaded from, so that
+// a resumed crawl knows whether it must request them again.
+func (p *IndexerPool) markCrawled(response *model.Response, index int64, state int) {
+	links := append([]*url.URL{response.Url}, response.Aliases...)
+	if response.Canonical != nil {
+		links = append(links, response.Canonical)
+	}
 
-		words = fp.Map(words, query.Normalize)
-		frequencies := tf_idf(words)
-		p.indexStore.PutAllWords(document.Index, frequencies)
+	crawlURLs := fp.Map(links, func(link *url.URL) *store.CrawlURL {
+		return &store.CrawlURL{Url: curate.Canonicalize(link).String(), Index: index, Depth: -1, State: state}
+	})
+	err := p.crawlStore.PutURLs(crawlURLs)
+	if err != nil {
+		log.Printf("WARNING: Unable to store the crawl state of %v because '%v'", response.Url.String(), err.Error())
 	}
 }
 
-func parseHTML(text string, baseURL *url.URL) (*model.Document, []string, []*url.URL, error) {
+// Parses the document and returns the text of the fields that are indexed
+// separately and the language the document claims to be in.
+func parseHTML(text string, baseURL *url.URL) (*model.Document, string, map[int]string, []*model.Link, error) {
 
 	doc, err := htmlquery.Parse(strings.NewReader(text))
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, "", nil, nil, err
 	}
 	body := htmlquery.FindOne(doc, "//body")
-	bodyText := htmlquery.InnerText(body)
+	bodyText := textContent(body)
 
 	// Find all links this documents links to
-	links := []*url.URL{}
-	anchors := htmlquery.Find(body, "//a/@href")
+	links := []*model.Link{}
+	anchors := htmlquery.Find(body, "//a[@href]")
 	for _, anchor := range anchors {
 		href := htmlquery.SelectAttr(anchor, "href")
 		link, err := parseUrlFrom(href, baseURL)
 		if err != nil {
 			continue
 		}
-		links = append(links, link)
+		links = append(links, &model.Link{
+			Url:  link,
+			Text: strings.Join(strings.Fields(textContent(anchor)), " "),
+		})
 	}
 
 	// Find the title
@@ -111,10 +254,12 @@ func parseHTML(text string, baseURL *url.URL) (*model.Document, []string, []*url
 		Title:       "",
 		Description: "",
 		Url:         baseURL,
+		Body:        bodyForSnippets(bodyText),
 	}
 	if title := htmlquery.FindOne(doc, "//title"); title != nil {
 		document.Title = htmlquery.InnerText(title)
 	}
+	titleText := document.Title
 	if document.Title == "" {
 		document.Title = baseURL.String()
 	}
@@ -154,11 +299,50 @@ func parseHTML(text string, baseURL *url.URL) (*model.Document, []string, []*url
 		}
 	}
 
-	// Get a list of words in that document
-	// FIXME: We need to split on lots more words
-	words := strings.Fields(bodyText)
-	return document, words, links, nil
+	headingTexts := []string{}
+	for _, heading := range htmlquery.Find(body, "//h1|//h2|//h3|//h4|//h5|//h6") {
+		headingTexts = append(headingTexts, textContent(heading))
+	}
+
+	descriptionText := ""
+	if meta := htmlquery.FindOne(doc, "//meta[@name='description']/@content"); meta != nil {
+		descriptionText = htmlquery.SelectAttr(meta, "content")
+	}
+
+	htmlLang := ""
+	if lang := htmlquery.FindOne(doc, "//html/@lang"); lang != nil {
+		htmlLang = htmlquery.SelectAttr(lang, "lang")
+	}
+
+	fields := map[int]string{
+		store.FIELD_BODY:        bodyText,
+		store.FIELD_TITLE:       titleText,
+		store.FIELD_HEADING:     strings.Join(headingTexts, " "),
+		store.FIELD_URL:         urlText(baseURL),
+		store.FIELD_DESCRIPTION: descriptionText,
+	}
+	return document, htmlLang, fields, links, nil
+
+}
+
+// Collapses the whitespace of the body text and cuts it at a word boundary if
+// it is too long.
+func bodyForSnippets(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= MAX_BODY_LEN {
+		return text
+	}
+	if end := strings.LastIndexByte(text[:MAX_BODY_LEN], ' '); end > 0 {
+		return text[:end]
+	}
+	return strings.ToValidUTF8(text[:MAX_BODY_LEN], "")
+}
 
+// Returns the path of the url as text, so that /linux-kernel/intro.html can
+// be found with "kernel". The file extension is left out as it says nothing
+// about the content.
+func urlText(link *url.URL) string {
+	return strings.TrimSuffix(link.Path, path.Ext(link.Path))
 }
 
 func parseUrlFrom(link string, baseURL *url.URL) (*url.URL, error) {
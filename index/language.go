@@ -0,0 +1,150 @@
+package index
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/flofriday/websearch/query"
+)
+
+// A language the documents are detected in.
+type Language struct {
+	// The ISO 639-1 code
+	Code string
+	Name string
+}
+
+// All languages detectLanguage knows from their text alone. Documents in
+// other languages are only recognized if they declare their language.
+var Languages = []Language{
+	{Code: "en", Name: "English"},
+	{Code: "de", Name: "German"},
+	{Code: "fr", Name: "French"},
+	{Code: "es", Name: "Spanish"},
+}
+
+// The n-gram detection needs some text to be reliable
+const MIN_DETECTION_LETTERS = 40
+
+// Only the start of long documents is looked at, which is plenty
+const MAX_DETECTION_LETTERS = 4000
+
+// How much more likely, per n-gram, the detected language must be than the
+// next one
+const MIN_DETECTION_MARGIN = 0.1
+
+// The length of the character n-grams
+const NGRAM_SIZE = 3
+
+// Returns the primary language of a language tag. Lists like "de, en" (as
+// Content-Language allows them) return the first one.
+func primaryLanguage(tag string) string {
+	tag, _, _ = strings.Cut(tag, ",")
+	return query.NormalizeLanguage(tag)
+}
+
+// Detects the language of a document. A language declared in the html
+// (<html lang>) wins over the Content-Language header, if neither exists the
+// text is compared with the n-gram profiles. Returns "" if the language is
+// unknown.
+func detectLanguage(htmlLang string, contentLanguage string, text string) string {
+	if language := primaryLanguage(htmlLang); language != "" {
+		return language
+	}
+	if language := primaryLanguage(contentLanguage); language != "" {
+		return language
+	}
+	return detectTextLanguage(text)
+}
+
+// The n-grams of the text, words are padded with a space on both sides so
+// that beginnings and endings of words are n-grams too.
+func ngrams(text string, limit int) map[string]int {
+	counts := map[string]int{}
+	letters := 0
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+NGRAM_SIZE <= len(runes); i++ {
+			counts[string(runes[i:i+NGRAM_SIZE])]++
+		}
+		letters += len(runes) - 2
+		if letters >= limit {
+			break
+		}
+	}
+	return counts
+}
+
+// The log probability of every n-gram in a language, learned from a sample
+// text.
+type languageProfile struct {
+	code          string
+	logProbs      map[string]float64
+	unseenLogProb float64
+}
+
+var languageProfiles = func() []*languageProfile {
+	profiles := []*languageProfile{}
+	for code, sample := range languageSamples {
+		counts := ngrams(sample, math.MaxInt)
+		total := 0
+		for _, count := range counts {
+			total += count
+		}
+
+		// Add-one smoothing, so unseen n-grams don't rule out a language
+		denominator := float64(total + len(counts) + 1)
+		profile := &languageProfile{
+			code:          code,
+			logProbs:      make(map[string]float64, len(counts)),
+			unseenLogProb: math.Log(1 / denominator),
+		}
+		for ngram, count := range counts {
+			profile.logProbs[ngram] = math.Log(float64(count+1) / denominator)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}()
+
+// Detects the language of the text with a naive Bayes classifier over
+// character n-grams. Returns "" if the text is too short or no language is
+// clearly more likely than the others.
+func detectTextLanguage(text string) string {
+	counts := ngrams(text, MAX_DETECTION_LETTERS)
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if total < MIN_DETECTION_LETTERS {
+		return ""
+	}
+
+	best, second := math.Inf(-1), math.Inf(-1)
+	bestCode := ""
+	for _, profile := range languageProfiles {
+		score := 0.0
+		for ngram, count := range counts {
+			logProb, ok := profile.logProbs[ngram]
+			if !ok {
+				logProb = profile.unseenLogProb
+			}
+			score += float64(count) * logProb
+		}
+		if score > best {
+			best, second = score, best
+			bestCode = profile.code
+		} else if score > second {
+			second = score
+		}
+	}
+
+	// The average difference per n-gram must be noticeable, otherwise the
+	// text is probably in a related language we have no profile for (like
+	// Italian, which looks a lot like Spanish)
+	if (best-second)/float64(total) < MIN_DETECTION_MARGIN {
+		return ""
+	}
+	return bestCode
+}
@@ -0,0 +1,88 @@
+package index
+
+// Sample texts the n-gram profiles of the languages are learned from. They
+// should consist of ordinary sentences with the most common words of the
+// language, as those dominate every text.
+var languageSamples = map[string]string{
+	"en": `
+The search engine downloads pages from the web and stores them in an index, so
+that people can find what they are looking for. When you type a few words into
+the box, it looks up every page that contains them and shows the best ones
+first. This is not as easy as it sounds, because there are billions of pages
+and most of them are not very useful. Some of the pages are written by people
+who want to sell something, others are copies of each other and many of them
+have not been changed for years. We have to decide which pages are worth
+keeping and which ones we should throw away. It would be nice if we could read
+all of them, but that would take far too long. Instead we follow the links
+between the pages and hope that the good ones are linked more often than the
+bad ones. Over the last weeks we have also been working on the way the results
+are ranked. The title of a page is usually more important than the text at the
+bottom, and the words other people use to describe a page are often better than
+the words the page uses itself. There is still a lot to do, but we think that
+the results are already quite good and they should get better with every
+change. If you would like to help, please have a look at the issues and let us
+know what you think about it. Thank you for reading this far and have a great
+day, we are looking forward to hearing from you soon.
+`,
+	"de": `
+Die Suchmaschine lädt Seiten aus dem Internet herunter und speichert sie in
+einem Index, damit die Leute finden können, wonach sie suchen. Wenn man ein paar
+Wörter in das Feld eingibt, sucht sie alle Seiten heraus, in denen diese
+vorkommen, und zeigt die besten zuerst an. Das ist nicht so einfach, wie es
+klingt, denn es gibt Milliarden von Seiten und die meisten davon sind nicht
+besonders nützlich. Manche Seiten werden von Leuten geschrieben, die etwas
+verkaufen wollen, andere sind Kopien voneinander und viele wurden seit Jahren
+nicht mehr geändert. Wir müssen also entscheiden, welche Seiten es wert sind,
+behalten zu werden, und welche wir wegwerfen sollten. Es wäre schön, wenn wir
+alle lesen könnten, aber das würde viel zu lange dauern. Stattdessen folgen wir
+den Links zwischen den Seiten und hoffen, dass die guten häufiger verlinkt
+werden als die schlechten. In den letzten Wochen haben wir außerdem daran
+gearbeitet, wie die Ergebnisse sortiert werden. Der Titel einer Seite ist
+meistens wichtiger als der Text ganz unten, und die Wörter, mit denen andere
+eine Seite beschreiben, sind oft besser als die Wörter der Seite selbst. Es gibt
+noch viel zu tun, aber wir glauben, dass die Ergebnisse schon ziemlich gut sind
+und mit jeder Änderung besser werden. Wenn du helfen möchtest, schau dir bitte
+die offenen Punkte an und sag uns, was du davon hältst. Vielen Dank fürs Lesen
+und einen schönen Tag, wir freuen uns darauf, bald von dir zu hören.
+`,
+	"fr": `
+Le moteur de recherche télécharge des pages sur le web et les enregistre dans un
+index, afin que les gens puissent trouver ce qu'ils cherchent. Quand on tape
+quelques mots dans le champ, il cherche toutes les pages qui les contiennent et
+affiche les meilleures en premier. Ce n'est pas aussi simple qu'il y paraît, car
+il existe des milliards de pages et la plupart d'entre elles ne sont pas très
+utiles. Certaines pages sont écrites par des gens qui veulent vendre quelque
+chose, d'autres sont des copies les unes des autres et beaucoup n'ont pas été
+modifiées depuis des années. Nous devons donc décider quelles pages méritent
+d'être gardées et lesquelles il faut jeter. Ce serait bien de pouvoir toutes les
+lire, mais cela prendrait beaucoup trop de temps. Nous suivons plutôt les liens
+entre les pages en espérant que les bonnes sont plus souvent citées que les
+mauvaises. Ces dernières semaines, nous avons aussi travaillé sur la manière de
+classer les résultats. Le titre d'une page est généralement plus important que
+le texte tout en bas, et les mots que les autres utilisent pour décrire une page
+sont souvent meilleurs que ceux de la page elle-même. Il reste encore beaucoup à
+faire, mais nous pensons que les résultats sont déjà assez bons et qu'ils
+s'amélioreront avec chaque changement. Si vous voulez nous aider, jetez un coup
+d'œil aux problèmes ouverts et dites-nous ce que vous en pensez. Merci d'avoir lu
+jusqu'ici et bonne journée, nous avons hâte d'avoir de vos nouvelles.
+`,
+	"es": `
+El buscador descarga páginas de la web y las guarda en un índice, para que la
+gente pueda encontrar lo que está buscando. Cuando escribes algunas palabras en
+el cuadro, busca todas las páginas que las contienen y muestra primero las
+mejores. No es tan fácil como parece, porque hay miles de millones de páginas y
+la mayoría no son muy útiles. Algunas páginas las escriben personas que quieren
+vender algo, otras son copias unas de otras y muchas no se han cambiado desde
+hace años. Tenemos que decidir qué páginas vale la pena conservar y cuáles
+deberíamos tirar. Sería bonito poder leerlas todas, pero eso llevaría demasiado
+tiempo. En cambio, seguimos los enlaces entre las páginas y esperamos que las
+buenas estén enlazadas con más frecuencia que las malas. En las últimas semanas
+también hemos trabajado en la forma de ordenar los resultados. El título de una
+página suele ser más importante que el texto de abajo, y las palabras que otras
+personas usan para describir una página a menudo son mejores que las palabras
+de la propia página. Todavía queda mucho por hacer, pero creemos que los
+resultados ya son bastante buenos y que mejorarán con cada cambio. Si quieres
+ayudar, echa un vistazo a los problemas abiertos y dinos lo que piensas. Gracias
+por leer hasta aquí y que tengas un buen día, esperamos saber de ti pronto.
+`,
+}
@@ -0,0 +1,14 @@
+package index
+
+import (
+	"github.com/flofriday/websearch/query"
+)
+
+// Returns the positions at which every term occurs.
+func termPositions(tokens []query.Token) map[string][]int {
+	positions := map[string][]int{}
+	for _, token := range tokens {
+		positions[token.Term] = append(positions[token.Term], token.Position)
+	}
+	return positions
+}
@@ -0,0 +1,113 @@
+package index
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"sync"
+)
+
+// The number of consecutive words hashed together as one feature.
+const SHINGLE_SIZE = 3
+
+// Computes the 64 bit SimHash of the words, where every shingle of
+// SHINGLE_SIZE words is a feature. Documents with similar content have
+// fingerprints that differ only in a few bits. Returns 0 without words.
+func simHash(words []string) uint64 {
+	if len(words) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	n := len(words) - SHINGLE_SIZE + 1
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		end := i + SHINGLE_SIZE
+		if end > len(words) {
+			end = len(words)
+		}
+
+		hasher := fnv.New64a()
+		hasher.Write([]byte(strings.Join(words[i:end], " ")))
+		hash := hasher.Sum64()
+
+		for bit := 0; bit < 64; bit++ {
+			if hash&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit := 0; bit < 64; bit++ {
+		if weights[bit] > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// Finds fingerprints within a maximum hamming distance. The fingerprints are
+// split into maxDistance+1 bands and two fingerprints within the distance must
+// have at least one identical band, so we only need to compare the candidates
+// that share a band.
+type fingerprintIndex struct {
+	maxDistance int
+	bandBits    int
+	bands       []map[uint64][]int64
+	fingerprint map[int64]uint64
+	lock        sync.Mutex
+}
+
+func newFingerprintIndex(maxDistance int) *fingerprintIndex {
+	numBands := maxDistance + 1
+	if numBands < 1 {
+		numBands = 1
+	}
+
+	index := &fingerprintIndex{
+		maxDistance: maxDistance,
+		bandBits:    (64 + numBands - 1) / numBands,
+		bands:       make([]map[uint64][]int64, numBands),
+		fingerprint: map[int64]uint64{},
+	}
+	for i := range index.bands {
+		index.bands[i] = map[uint64][]int64{}
+	}
+	return index
+}
+
+func (f *fingerprintIndex) band(fingerprint uint64, i int) uint64 {
+	shift := i * f.bandBits
+	if shift >= 64 {
+		return 0
+	}
+	return (fingerprint >> shift) & (1<<f.bandBits - 1)
+}
+
+// Adds the fingerprint to the index unless a near-duplicate is already in it,
+// in which case the id of the duplicate is returned. Checking and adding
+// happens atomically, so two duplicates indexed at the same time are caught.
+func (f *fingerprintIndex) addUnique(id int64, fingerprint uint64) (int64, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for i, band := range f.bands {
+		for _, candidate := range band[f.band(fingerprint, i)] {
+			if bits.OnesCount64(fingerprint^f.fingerprint[candidate]) <= f.maxDistance {
+				return candidate, false
+			}
+		}
+	}
+
+	f.fingerprint[id] = fingerprint
+	for i, band := range f.bands {
+		key := f.band(fingerprint, i)
+		band[key] = append(band[key], id)
+	}
+	return id, true
+}
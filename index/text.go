@@ -0,0 +1,53 @@
+package index
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Elements that don't separate words, everything else (paragraphs, list
+// items, table cells, ...) does.
+var inlineElements = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "cite": true,
+	"code": true, "data": true, "dfn": true, "em": true, "i": true, "kbd": true,
+	"mark": true, "q": true, "s": true, "samp": true, "small": true, "span": true,
+	"strong": true, "sub": true, "sup": true, "time": true, "u": true, "var": true,
+}
+
+// Elements whose content is not text for humans
+var ignoredElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+}
+
+// Returns the text of the node. Unlike htmlquery.InnerText, words in
+// different blocks are separated by a space, so "<p>end.</p><p>Start</p>"
+// doesn't become "end.Start".
+func textContent(node *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if ignoredElements[n.Data] {
+				return
+			}
+		}
+
+		block := n.Type == html.ElementNode && !inlineElements[n.Data]
+		if block {
+			b.WriteString(" ")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if block {
+			b.WriteString(" ")
+		}
+	}
+	walk(node)
+	return b.String()
+}
@@ -9,4 +9,10 @@ type Document struct {
 	Description string
 	Url         *url.URL
 	Icon        *url.URL
+	// The SimHash of the content
+	Fingerprint uint64
+	// The ISO 639-1 code, empty if unknown
+	Language string
+	// The text of the body with collapsed whitespace, for snippets
+	Body string
 }
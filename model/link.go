@@ -0,0 +1,12 @@
+package model
+
+import "net/url"
+
+// A link found in a document
+type Link struct {
+	Url *url.URL
+	// How many links away from a seed the target is
+	Depth int
+	// The text between the <a> tags
+	Text string
+}
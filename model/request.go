@@ -5,4 +5,5 @@ import "net/url"
 type Request struct {
 	Index int64
 	Url   *url.URL
+	Depth int
 }
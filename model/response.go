@@ -7,4 +7,12 @@ type Response struct {
 	Url        *url.URL
 	Redirected []*url.URL
 	Content    string
+	Depth      int
+	// The url under which the document should be stored, which can differ
+	// from the url it was downloaded from.
+	Canonical *url.URL
+	// The other urls the document was found under, like redirects
+	Aliases []*url.URL
+	// The Content-Language header
+	ContentLanguage string
 }
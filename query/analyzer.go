@@ -0,0 +1,111 @@
+package query
+
+import (
+	"sort"
+	"strings"
+)
+
+// An Analyzer turns text into the terms that are indexed and searched for.
+// Every language has its own, the same one must be used for a document and
+// the queries on it.
+type Analyzer struct {
+	// The ISO 639-1 code, empty for the default analyzer
+	Language string
+	// Words that are not indexed
+	Stopwords map[string]bool
+	// Reduces a word to its stem, nil to keep words as they are
+	Stem func(string) string
+}
+
+// Used for documents in languages without an analyzer of their own. It
+// neither removes stopwords nor stems, as it can't know how.
+var DefaultAnalyzer = &Analyzer{}
+
+var analyzers = map[string]*Analyzer{}
+
+func init() {
+	RegisterAnalyzer(&Analyzer{Language: "en", Stopwords: englishStopwords, Stem: StemEnglish})
+	RegisterAnalyzer(&Analyzer{Language: "de", Stopwords: germanStopwords, Stem: StemGerman})
+}
+
+// Makes the analyzer available for its language. Must not be called while
+// documents are indexed or searched.
+func RegisterAnalyzer(analyzer *Analyzer) {
+	analyzers[analyzer.Language] = analyzer
+}
+
+// Returns the analyzer for the language, which is the DefaultAnalyzer if
+// there is none.
+func AnalyzerFor(language string) *Analyzer {
+	if analyzer, ok := analyzers[language]; ok {
+		return analyzer
+	}
+	return DefaultAnalyzer
+}
+
+// Returns all registered analyzers and the DefaultAnalyzer.
+func Analyzers() []*Analyzer {
+	all := []*Analyzer{DefaultAnalyzer}
+	for _, analyzer := range analyzers {
+		all = append(all, analyzer)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Language < all[j].Language })
+	return all
+}
+
+// Whether the analyzer handles documents in the language.
+func (a *Analyzer) Handles(language string) bool {
+	return AnalyzerFor(language) == a
+}
+
+func (a *Analyzer) IsStopword(term string) bool {
+	return a.Stopwords[term]
+}
+
+// Removes the stopwords and stems the remaining terms. The positions of the
+// tokens are kept, so phrases with stopwords in them still match.
+func (a *Analyzer) Filter(tokens []Token) []Token {
+	filtered := make([]Token, 0, len(tokens))
+	for _, token := range tokens {
+		if a.Stopwords[token.Term] {
+			continue
+		}
+		if a.Stem != nil {
+			token.Term = a.Stem(token.Term)
+		}
+		filtered = append(filtered, token)
+	}
+	return filtered
+}
+
+// Splits the text into the terms to index.
+func (a *Analyzer) Analyze(text string) []Token {
+	return a.Filter(TokenizeSpans(text))
+}
+
+// Whether the term is a stopword in any language.
+func isAnyStopword(term string) bool {
+	for _, analyzer := range analyzers {
+		if analyzer.IsStopword(term) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the primary language of a language tag, "en" for "en-US" or "EN",
+// and "" if it isn't one.
+func NormalizeLanguage(tag string) string {
+	tag, _, _ = strings.Cut(strings.TrimSpace(tag), "-")
+	tag, _, _ = strings.Cut(tag, "_")
+	tag = strings.ToLower(tag)
+	if len(tag) < 2 || len(tag) > 3 {
+		return ""
+	}
+	for _, r := range tag {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+	return tag
+}
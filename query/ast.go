@@ -0,0 +1,148 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A node of the syntax tree of a query.
+type Node interface {
+	String() string
+}
+
+// A word or a phrase, which might be restricted to a single field.
+type TermNode struct {
+	// More than one word must appear next to each other in this order
+	Words []string
+	// Negative if the term may appear in any field
+	Field int
+}
+
+// All children must match.
+type AndNode struct {
+	Children []Node
+}
+
+// At least one child must match.
+type OrNode struct {
+	Children []Node
+}
+
+// Matches all documents in the language.
+type LanguageNode struct {
+	// The ISO 639-1 code
+	Language string
+}
+
+// Excludes the documents the child matches.
+type NotNode struct {
+	Child Node
+}
+
+func (n *TermNode) String() string {
+	text := strings.Join(n.Words, " ")
+	if len(n.Words) > 1 {
+		text = "\"" + text + "\""
+	}
+	if n.Field >= 0 {
+		text = FieldName(n.Field) + ":" + text
+	}
+	return text
+}
+
+// Identifies equal terms, so they are only looked up once.
+func (n *TermNode) key() string {
+	return fmt.Sprintf("%v:%v", n.Field, strings.Join(n.Words, " "))
+}
+
+func (n *LanguageNode) String() string {
+	return "lang:" + n.Language
+}
+
+func (n *AndNode) String() string {
+	return "(" + strings.Join(mapNodes(n.Children), " AND ") + ")"
+}
+
+func (n *OrNode) String() string {
+	return "(" + strings.Join(mapNodes(n.Children), " OR ") + ")"
+}
+
+func (n *NotNode) String() string {
+	return "-" + n.Child.String()
+}
+
+func mapNodes(nodes []Node) []string {
+	strs := make([]string, len(nodes))
+	for i, node := range nodes {
+		strs[i] = node.String()
+	}
+	return strs
+}
+
+// Returns the terms the documents are scored by, which are all that aren't
+// excluded.
+func positiveTerms(node Node) []*TermNode {
+	switch n := node.(type) {
+	case *TermNode:
+		return []*TermNode{n}
+	case *AndNode:
+		terms := []*TermNode{}
+		for _, child := range n.Children {
+			terms = append(terms, positiveTerms(child)...)
+		}
+		return terms
+	case *OrNode:
+		terms := []*TermNode{}
+		for _, child := range n.Children {
+			terms = append(terms, positiveTerms(child)...)
+		}
+		return terms
+	}
+	return nil
+}
+
+// Returns all terms, including the excluded ones.
+func allTerms(node Node) []*TermNode {
+	switch n := node.(type) {
+	case *TermNode:
+		return []*TermNode{n}
+	case *AndNode:
+		terms := []*TermNode{}
+		for _, child := range n.Children {
+			terms = append(terms, allTerms(child)...)
+		}
+		return terms
+	case *OrNode:
+		terms := []*TermNode{}
+		for _, child := range n.Children {
+			terms = append(terms, allTerms(child)...)
+		}
+		return terms
+	case *NotNode:
+		return allTerms(n.Child)
+	}
+	return nil
+}
+
+// Whether the query contains a LanguageNode.
+func filtersLanguage(node Node) bool {
+	switch n := node.(type) {
+	case *LanguageNode:
+		return true
+	case *AndNode:
+		for _, child := range n.Children {
+			if filtersLanguage(child) {
+				return true
+			}
+		}
+	case *OrNode:
+		for _, child := range n.Children {
+			if filtersLanguage(child) {
+				return true
+			}
+		}
+	case *NotNode:
+		return filtersLanguage(n.Child)
+	}
+	return false
+}
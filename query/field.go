@@ -0,0 +1,33 @@
+package query
+
+import (
+	"strings"
+
+	"github.com/flofriday/websearch/store"
+)
+
+// The names of the fields as they are used in queries like `title:linux`
+var fieldNames = map[string]int{
+	"body":        store.FIELD_BODY,
+	"anchor":      store.FIELD_ANCHOR,
+	"title":       store.FIELD_TITLE,
+	"heading":     store.FIELD_HEADING,
+	"url":         store.FIELD_URL,
+	"description": store.FIELD_DESCRIPTION,
+}
+
+// Returns the field with that name.
+func ParseField(name string) (int, bool) {
+	field, ok := fieldNames[strings.ToLower(name)]
+	return field, ok
+}
+
+// Returns the name of the field.
+func FieldName(field int) string {
+	for name, f := range fieldNames {
+		if f == field {
+			return name
+		}
+	}
+	return ""
+}
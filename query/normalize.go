@@ -12,5 +12,7 @@ func Normalize(term string) string {
 	form := norm.NFKC
 	term = form.String(term)
 	term = strings.ToLower(term)
+	// Typographic apostrophes are the same as typewriter ones
+	term = strings.ReplaceAll(term, "’", "'")
 	return term
 }
@@ -0,0 +1,427 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The error for queries that can't be parsed. Position is the character
+// (starting at 1) where the problem was found, 0 if it is about the whole
+// query.
+type SyntaxError struct {
+	Position int
+	Message  string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Position == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%v (at character %v)", e.Message, e.Position)
+}
+
+type tokenKind int
+
+const (
+	TOKEN_EOF tokenKind = iota
+	TOKEN_TERM
+	TOKEN_AND
+	TOKEN_OR
+	TOKEN_NOT
+	TOKEN_LPAREN
+	TOKEN_RPAREN
+	// Like `lang:de`
+	TOKEN_LANG
+)
+
+type token struct {
+	kind tokenKind
+	// The character the token starts at
+	position int
+	// The byte the token starts at
+	offset int
+	text   string
+	// Only for TOKEN_TERM, the text of the word or phrase without quotes
+	term  string
+	field int
+}
+
+type lexer struct {
+	text   string
+	offset int
+}
+
+func (l *lexer) position(offset int) int {
+	return utf8.RuneCountInString(l.text[:offset]) + 1
+}
+
+func (l *lexer) errorf(offset int, format string, a ...any) *SyntaxError {
+	return &SyntaxError{Position: l.position(offset), Message: fmt.Sprintf(format, a...)}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// Where a word ends, which is at the next space, quote or parenthesis.
+func (l *lexer) wordEnd(start int) int {
+	end := strings.IndexFunc(l.text[start:], func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '(' || r == ')'
+	})
+	if end < 0 {
+		return len(l.text)
+	}
+	return start + end
+}
+
+// Reads the phrase starting at the quote.
+func (l *lexer) phrase(start int) (string, error) {
+	end := strings.IndexByte(l.text[start+1:], '"')
+	if end < 0 {
+		return "", l.errorf(start, "missing closing quote")
+	}
+	l.offset = start + 1 + end + 1
+	return l.text[start+1 : start+1+end], nil
+}
+
+func (l *lexer) next() (token, error) {
+	for l.offset < len(l.text) && isSpace(l.text[l.offset]) {
+		l.offset++
+	}
+	start := l.offset
+	tok := token{position: l.position(start), offset: start, field: -1}
+	if start >= len(l.text) {
+		tok.kind = TOKEN_EOF
+		return tok, nil
+	}
+
+	switch c := l.text[start]; {
+	case c == '(':
+		l.offset++
+		tok.kind = TOKEN_LPAREN
+		tok.text = "("
+		return tok, nil
+
+	case c == ')':
+		l.offset++
+		tok.kind = TOKEN_RPAREN
+		tok.text = ")"
+		return tok, nil
+
+	case c == '-' && start+1 < len(l.text) && !isSpace(l.text[start+1]):
+		l.offset++
+		tok.kind = TOKEN_NOT
+		tok.text = "-"
+		return tok, nil
+
+	case c == '"':
+		phrase, err := l.phrase(start)
+		if err != nil {
+			return tok, err
+		}
+		tok.kind = TOKEN_TERM
+		tok.text = l.text[start:l.offset]
+		tok.term = phrase
+		return tok, nil
+	}
+
+	end := l.wordEnd(start)
+	if end == start {
+		// A lone character that ends words, take it as a word
+		_, size := utf8.DecodeRuneInString(l.text[start:])
+		end = start + size
+	}
+	word := l.text[start:end]
+	l.offset = end
+	tok.text = word
+
+	switch word {
+	case "AND":
+		tok.kind = TOKEN_AND
+		return tok, nil
+	case "OR":
+		tok.kind = TOKEN_OR
+		return tok, nil
+	case "NOT":
+		tok.kind = TOKEN_NOT
+		return tok, nil
+	}
+
+	tok.kind = TOKEN_TERM
+	tok.term = word
+
+	// Unknown prefixes are searched as they are, so `c++:` or `http://`
+	// keep working.
+	name, rest, ok := strings.Cut(word, ":")
+	if !ok {
+		return tok, nil
+	}
+	if strings.ToLower(name) == "lang" {
+		if rest == "" {
+			return tok, l.errorf(start, "expected a language like 'lang:en'")
+		}
+		tok.kind = TOKEN_LANG
+		tok.term = rest
+		return tok, nil
+	}
+	field, ok := ParseField(name)
+	if !ok {
+		return tok, nil
+	}
+	tok.field = field
+	if rest != "" {
+		tok.term = rest
+		return tok, nil
+	}
+	if end < len(l.text) && l.text[end] == '"' {
+		phrase, err := l.phrase(end)
+		if err != nil {
+			return tok, err
+		}
+		tok.text = l.text[start:l.offset]
+		tok.term = phrase
+		return tok, nil
+	}
+	return tok, l.errorf(start, "expected a word or phrase after '%v'", word)
+}
+
+type parser struct {
+	lexer *lexer
+	token token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, a ...any) *SyntaxError {
+	return &SyntaxError{Position: p.token.position, Message: fmt.Sprintf(format, a...)}
+}
+
+// Parses a query. Words next to each other must all match, OR matches
+// either side and `-` or NOT excludes documents. AND binds stronger than OR,
+// parentheses group, quotes search for a phrase and `field:` restricts a word
+// or phrase to a single field. `lang:` matches the documents in a language.
+// Returns nil for queries without any terms.
+func Parse(text string) (Node, error) {
+	p := &parser{lexer: &lexer{text: text}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.token.kind == TOKEN_EOF {
+		return nil, nil
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.kind != TOKEN_EOF {
+		return nil, p.errorf("unexpected '%v'", p.token.text)
+	}
+
+	if err := validate(node); err != nil {
+		return nil, err
+	}
+	if node != nil && !matchesDocuments(node) {
+		return nil, &SyntaxError{Message: "excluded words must be combined with a word to search for, like 'linux -windows'"}
+	}
+	return node, nil
+}
+
+func (p *parser) parseOr() (Node, error) {
+	children := []Node{}
+	for {
+		child, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			children = append(children, child)
+		}
+
+		if p.token.kind != TOKEN_OR {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.token.kind == TOKEN_EOF || p.token.kind == TOKEN_RPAREN || p.token.kind == TOKEN_OR {
+			return nil, p.errorf("expected a word after OR")
+		}
+	}
+
+	return simplify(children, func(c []Node) Node { return &OrNode{Children: c} }), nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	if p.token.kind == TOKEN_OR || p.token.kind == TOKEN_AND {
+		return nil, p.errorf("unexpected %v", p.token.text)
+	}
+
+	children := []Node{}
+	for p.token.kind != TOKEN_EOF && p.token.kind != TOKEN_RPAREN && p.token.kind != TOKEN_OR {
+		if p.token.kind == TOKEN_AND {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if p.token.kind != TOKEN_TERM && p.token.kind != TOKEN_LANG && p.token.kind != TOKEN_NOT && p.token.kind != TOKEN_LPAREN {
+				return nil, p.errorf("expected a word after AND")
+			}
+		}
+
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			children = append(children, child)
+		}
+	}
+
+	return simplify(children, func(c []Node) Node { return &AndNode{Children: c} }), nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.token.kind != TOKEN_NOT {
+		return p.parsePrimary()
+	}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	child, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if child == nil {
+		return nil, nil
+	}
+	// Two exclusions cancel each other out
+	if not, ok := child.(*NotNode); ok {
+		return not.Child, nil
+	}
+	return &NotNode{Child: child}, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	switch p.token.kind {
+	case TOKEN_TERM:
+		// Words the tokenizer splits up, like "e-mail", are searched as
+		// a phrase
+		words := Tokenize(p.token.term)
+		field := p.token.field
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		// Words that consist only of characters we don't index
+		if len(words) == 0 {
+			return nil, nil
+		}
+		return &TermNode{Words: words, Field: field}, nil
+
+	case TOKEN_LANG:
+		language := NormalizeLanguage(p.token.term)
+		if language == "" {
+			return nil, p.errorf("unknown language '%v', expected a code like 'en'", p.token.term)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return &LanguageNode{Language: language}, nil
+
+	case TOKEN_LPAREN:
+		open := p.token
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.token.kind == TOKEN_RPAREN {
+			return nil, p.errorf("empty parentheses")
+		}
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.token.kind != TOKEN_RPAREN {
+			return nil, &SyntaxError{Position: open.position, Message: "missing closing parenthesis"}
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return node, nil
+
+	case TOKEN_EOF:
+		return nil, p.errorf("unexpected end of the query")
+	}
+
+	return nil, p.errorf("unexpected '%v'", p.token.text)
+}
+
+// Avoids nodes with a single child.
+func simplify(children []Node, combine func([]Node) Node) Node {
+	switch len(children) {
+	case 0:
+		return nil
+	case 1:
+		return children[0]
+	}
+	return combine(children)
+}
+
+// Whether the node can match documents on its own. Exclusions can only
+// remove documents other terms found.
+func matchesDocuments(node Node) bool {
+	switch n := node.(type) {
+	case *TermNode, *LanguageNode:
+		return true
+	case *AndNode:
+		for _, child := range n.Children {
+			if matchesDocuments(child) {
+				return true
+			}
+		}
+		return false
+	case *OrNode:
+		for _, child := range n.Children {
+			if !matchesDocuments(child) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Checks the parts of the query that the grammar can't express.
+func validate(node Node) error {
+	switch n := node.(type) {
+	case *AndNode:
+		for _, child := range n.Children {
+			if err := validate(child); err != nil {
+				return err
+			}
+		}
+	case *OrNode:
+		for _, child := range n.Children {
+			if !matchesDocuments(child) {
+				return &SyntaxError{Message: fmt.Sprintf("'%v' only excludes words and can't be combined with OR", child)}
+			}
+			if err := validate(child); err != nil {
+				return err
+			}
+		}
+	case *NotNode:
+		if !matchesDocuments(n.Child) {
+			return &SyntaxError{Message: fmt.Sprintf("'%v' excludes nothing", n)}
+		}
+		return validate(n.Child)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package query
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"linux", "linux"},
+		{"Linux, Kernel", "(linux AND kernel)"},
+		{"a b", "(a AND b)"},
+		{"a AND b", "(a AND b)"},
+		{"a OR b", "(a OR b)"},
+		// AND binds stronger than OR
+		{"a b OR c", "((a AND b) OR c)"},
+		{"a OR b c", "(a OR (b AND c))"},
+		{"a (b OR c)", "(a AND (b OR c))"},
+		{"(a)", "a"},
+		{"a -b", "(a AND -b)"},
+		{"-a b", "(-a AND b)"},
+		{"a NOT b", "(a AND -b)"},
+		{"a -(b c)", "(a AND -(b AND c))"},
+		{"a -(b OR c)", "(a AND -(b OR c))"},
+		// Two exclusions cancel each other out
+		{"a --b", "(a AND b)"},
+		{"a -(-b)", "(a AND b)"},
+		// A lone dash is no exclusion
+		{"a - b", "(a AND b)"},
+		{"title:linux", "title:linux"},
+		{"TITLE:linux", "title:linux"},
+		{`title:"kernel module"`, `title:"kernel module"`},
+		{`"kernel module" linux`, `("kernel module" AND linux)`},
+		// Words the tokenizer splits are searched as a phrase
+		{"e-mail", `"e mail"`},
+		// Unknown prefixes are searched as they are
+		{"c++:x", `"c x"`},
+		{"lang:de linux", "(lang:de AND linux)"},
+		{"linux lang:DE", "(linux AND lang:de)"},
+		// Queries without any terms
+		{"", "<nil>"},
+		{"   ", "<nil>"},
+		{"-", "<nil>"},
+	}
+
+	for _, test := range tests {
+		node, err := Parse(test.query)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", test.query, err)
+			continue
+		}
+		got := "<nil>"
+		if node != nil {
+			got = node.String()
+		}
+		if got != test.want {
+			t.Errorf("Parse(%q) = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query    string
+		position int
+	}{
+		{"a OR", 5},
+		{"a OR OR b", 6},
+		{"OR a", 1},
+		{"AND", 1},
+		{"a AND", 6},
+		{"(a", 1},
+		{"a (b", 3},
+		{"a)", 2},
+		{"()", 2},
+		{"title:", 1},
+		{"linux title:", 7},
+		{`"`, 1},
+		{`a "b c`, 3},
+		{`title:"b c`, 7},
+		{"lang:", 1},
+		// Positions count characters, not bytes
+		{"über (a", 6},
+		// Problems with the whole query have no position
+		{"-a", 0},
+		{"-a -b", 0},
+		{"-(a b)", 0},
+		{"a OR -b", 0},
+		{"(a OR -b) c", 0},
+	}
+
+	for _, test := range tests {
+		node, err := Parse(test.query)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q) = %v, %v, want a syntax error", test.query, node, err)
+			continue
+		}
+		if syntaxErr.Position != test.position {
+			t.Errorf("Parse(%q) failed at %v, want %v (%v)", test.query, syntaxErr.Position, test.position, syntaxErr)
+		}
+	}
+}
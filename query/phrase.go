@@ -0,0 +1,86 @@
+package query
+
+import (
+	"github.com/flofriday/websearch/store"
+)
+
+type fieldKey struct {
+	index int64
+	field int
+}
+
+// Combines the postings of the words of a phrase into postings of the whole
+// phrase. A phrase occurs wherever its first word is followed by the other
+// words at their offsets, and the positions of those occurrences are kept.
+// The offsets are usually 0, 1, 2, ... but have gaps where stopwords were
+// removed.
+func matchPhrase(wordPostings [][]store.Posting, offsets []int) []store.Posting {
+	if len(wordPostings) == 0 {
+		return nil
+	}
+
+	// Where each of the following words occur, by document and field
+	following := make([]map[fieldKey]map[int]bool, len(wordPostings))
+	for i := 1; i < len(wordPostings); i++ {
+		following[i] = map[fieldKey]map[int]bool{}
+		for _, posting := range wordPostings[i] {
+			positions := map[int]bool{}
+			for _, position := range posting.Positions {
+				positions[position] = true
+			}
+			following[i][fieldKey{posting.Index, posting.Field}] = positions
+		}
+	}
+
+	phrasePostings := []store.Posting{}
+	for _, posting := range wordPostings[0] {
+		key := fieldKey{posting.Index, posting.Field}
+		starts := []int{}
+		for _, start := range posting.Positions {
+			matches := true
+			for i := 1; i < len(wordPostings); i++ {
+				if !following[i][key][start+offsets[i]-offsets[0]] {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				starts = append(starts, start)
+			}
+		}
+
+		if len(starts) == 0 {
+			continue
+		}
+		posting.Positions = starts
+		posting.Frequency = int64(len(starts))
+		phrasePostings = append(phrasePostings, posting)
+	}
+	return phrasePostings
+}
+
+// The smallest distance between any position in a and any in b. Both must be
+// sorted. Returns -1 if either is empty.
+func minDistance(a []int, b []int) int {
+	if len(a) == 0 || len(b) == 0 {
+		return -1
+	}
+
+	best := -1
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		distance := a[i] - b[j]
+		if distance < 0 {
+			distance = -distance
+		}
+		if best < 0 || distance < best {
+			best = distance
+		}
+		if a[i] < b[j] {
+			i++
+		} else {
+			j++
+		}
+	}
+	return best
+}
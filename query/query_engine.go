@@ -1,22 +1,80 @@
 package query
 
 import (
+	"fmt"
+	"math"
 	"sort"
-	"strings"
 
 	"github.com/flofriday/websearch/fp"
 	"github.com/flofriday/websearch/model"
 	"github.com/flofriday/websearch/store"
 )
 
+// Common defaults for the BM25 parameters
+const DEFAULT_K1 = 1.2
+const DEFAULT_B = 0.75
+const DEFAULT_RANK_WEIGHT = 0.5
+const DEFAULT_PROXIMITY_WEIGHT = 0.5
+
+// How much a term counts in each field compared to the body
+const DEFAULT_BODY_BOOST = 1.0
+const DEFAULT_ANCHOR_BOOST = 1.5
+const DEFAULT_TITLE_BOOST = 3.0
+const DEFAULT_HEADING_BOOST = 2.0
+const DEFAULT_URL_BOOST = 1.5
+const DEFAULT_DESCRIPTION_BOOST = 1.5
+
+// How many times more results a corrected query must find to be suggested
+const CORRECTION_RESULTS_RATIO = 2
+
+// Queries with more results than this are never corrected
+const CORRECTION_MAX_RESULTS = 3
+
 type QueryEngine struct {
 	IndexStore    store.IndexStore
 	DocumentStore store.DocumentStore
+	// How quickly the score saturates with the term frequency
+	K1 float64
+	// How strongly the score is normalized by the document length
+	B float64
+	// How much the PageRank influences the score, 0 ignores it
+	RankWeight float64
+	// How much documents with the query terms close together are preferred
+	ProximityWeight float64
+	// Fields without a boost are ignored
+	FieldBoosts map[int]float64
+	// Suggests corrections for misspelled queries, nil disables them
+	SpellChecker *SpellChecker
+}
+
+func NewQueryEngine(indexStore store.IndexStore, documentStore store.DocumentStore) *QueryEngine {
+	return &QueryEngine{
+		IndexStore:      indexStore,
+		DocumentStore:   documentStore,
+		K1:              DEFAULT_K1,
+		B:               DEFAULT_B,
+		RankWeight:      DEFAULT_RANK_WEIGHT,
+		ProximityWeight: DEFAULT_PROXIMITY_WEIGHT,
+		FieldBoosts: map[int]float64{
+			store.FIELD_BODY:        DEFAULT_BODY_BOOST,
+			store.FIELD_ANCHOR:      DEFAULT_ANCHOR_BOOST,
+			store.FIELD_TITLE:       DEFAULT_TITLE_BOOST,
+			store.FIELD_HEADING:     DEFAULT_HEADING_BOOST,
+			store.FIELD_URL:         DEFAULT_URL_BOOST,
+			store.FIELD_DESCRIPTION: DEFAULT_DESCRIPTION_BOOST,
+		},
+	}
 }
 
 type QueryResult struct {
 	Documents []*model.Document
+	// The score and snippet of every document, in the same order
+	Scores    []float64
+	Snippets  []*Snippet
 	TotalDocs int64
+	// A spelling correction of the query that finds more documents, empty
+	// if there is none
+	Correction string
 }
 
 type rankedIndex struct {
@@ -24,23 +82,338 @@ type rankedIndex struct {
 	rank  float64
 }
 
-func (e *QueryEngine) Find(text string, number int) (*QueryResult, error) {
-	words := strings.Split(text, " ")
-	words = fp.Map(words, Normalize)
+// The inverse document frequency, rare terms are worth more than common ones.
+// This variant is never negative, even for terms in more than half of the
+// documents.
+func idf(docCount int64, docFrequency int64) float64 {
+	n := float64(docCount)
+	df := float64(docFrequency)
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
 
-	indexRanks := map[int64]float64{}
-	for _, word := range words {
-		indecies, frequencies, err := e.IndexStore.Get(word)
+// The term frequency of a single field, normalized by the field length and
+// weighted by the field boost. This is the BM25F way to combine fields: the
+// weighted frequencies are summed before the saturation, so a term that
+// appears in many fields doesn't count as many separate terms.
+func (e *QueryEngine) fieldFrequency(posting store.Posting, avgLength float64) float64 {
+	norm := 1 - e.B
+	if avgLength > 0 {
+		norm += e.B * float64(posting.Length) / avgLength
+	}
+	return e.FieldBoosts[posting.Field] * float64(posting.Frequency) / norm
+}
+
+// The BM25 score of a single term in a single document.
+func (e *QueryEngine) bm25(idf float64, tf float64) float64 {
+	return idf * tf * (e.K1 + 1) / (tf + e.K1)
+}
+
+// The postings of a term, phrases are matched by their positions. The words
+// are analyzed like the documents they are looked up in, so every analyzer
+// searches only the documents in its language. Stopwords aren't indexed, so a
+// term of nothing but stopwords matches every document in the language of
+// the analyzer, with postings that don't count for the score.
+func (e *QueryEngine) termPostings(t *TermNode, languages func() (map[int64]string, error)) ([]store.Posting, error) {
+	tokens := make([]Token, len(t.Words))
+	for i, word := range t.Words {
+		tokens[i] = Token{Term: word, Position: i}
+	}
+
+	cache := map[string][]store.Posting{}
+	termPostings := []store.Posting{}
+	for _, analyzer := range Analyzers() {
+		analyzed := analyzer.Filter(tokens)
+		if len(analyzed) == 0 {
+			documentLanguages, err := languages()
+			if err != nil {
+				return nil, err
+			}
+			for index, language := range documentLanguages {
+				if analyzer.Handles(language) {
+					termPostings = append(termPostings, store.Posting{Index: index, Field: -1, Language: language})
+				}
+			}
+			continue
+		}
+
+		wordPostings := [][]store.Posting{}
+		for _, token := range analyzed {
+			postings, ok := cache[token.Term]
+			if !ok {
+				var err error
+				postings, err = e.IndexStore.Get(token.Term)
+				if err != nil {
+					return nil, err
+				}
+				cache[token.Term] = postings
+			}
+
+			postings = fp.Filter(postings, func(p store.Posting) bool {
+				return analyzer.Handles(p.Language) && (t.Field < 0 || p.Field == t.Field)
+			})
+			wordPostings = append(wordPostings, postings)
+		}
+
+		if len(wordPostings) == 1 {
+			termPostings = append(termPostings, wordPostings[0]...)
+			continue
+		}
+		offsets := fp.Map(analyzed, func(t Token) int { return t.Position })
+		termPostings = append(termPostings, matchPhrase(wordPostings, offsets)...)
+	}
+	return termPostings, nil
+}
+
+// Rewards documents in which the terms appear close to each other. For every
+// pair of neighbouring terms the closest occurrences in the same field count,
+// so "new york" beats a document with "new" in the title and "york" in the
+// footer. Returns a value between 0 and 1.
+func proximity(index int64, termPositions []map[int64]map[int][]int) float64 {
+	if len(termPositions) < 2 {
+		return 0
+	}
+
+	sum := 0.0
+	for i := 1; i < len(termPositions); i++ {
+		best := -1
+		for field, positions := range termPositions[i-1][index] {
+			distance := minDistance(positions, termPositions[i][index][field])
+			if distance > 0 && (best < 0 || distance < best) {
+				best = distance
+			}
+		}
+		if best > 0 {
+			sum += 1 / float64(best)
+		}
+	}
+	return sum / float64(len(termPositions)-1)
+}
+
+// Returns the documents matching the node. The languages of the documents
+// are only needed if the query filters by language.
+func evaluate(node Node, termPostings map[string][]store.Posting, languages map[int64]string) map[int64]bool {
+	switch n := node.(type) {
+	case *LanguageNode:
+		matches := map[int64]bool{}
+		for index, language := range languages {
+			if language == n.Language {
+				matches[index] = true
+			}
+		}
+		return matches
+
+	case *TermNode:
+		matches := map[int64]bool{}
+		for _, posting := range termPostings[n.key()] {
+			matches[posting.Index] = true
+		}
+		return matches
+
+	case *AndNode:
+		var matches map[int64]bool
+		for _, child := range n.Children {
+			if !matchesDocuments(child) {
+				continue
+			}
+			childMatches := evaluate(child, termPostings, languages)
+			if matches == nil {
+				matches = childMatches
+				continue
+			}
+			for index := range matches {
+				if !childMatches[index] {
+					delete(matches, index)
+				}
+			}
+		}
+		for _, child := range n.Children {
+			if !matchesDocuments(child) {
+				for index := range excluded(child, termPostings, languages) {
+					delete(matches, index)
+				}
+			}
+		}
+		return matches
+
+	case *OrNode:
+		matches := map[int64]bool{}
+		for _, child := range n.Children {
+			for index := range evaluate(child, termPostings, languages) {
+				matches[index] = true
+			}
+		}
+		return matches
+	}
+
+	// The parser makes sure exclusions are always part of an AndNode
+	return map[int64]bool{}
+}
+
+// Returns the documents a node that can't match on its own removes from the
+// results, like `-linux` or `(-linux -windows)`.
+func excluded(node Node, termPostings map[string][]store.Posting, languages map[int64]string) map[int64]bool {
+	switch n := node.(type) {
+	case *NotNode:
+		return evaluate(n.Child, termPostings, languages)
+	case *AndNode:
+		matches := map[int64]bool{}
+		for _, child := range n.Children {
+			for index := range excluded(child, termPostings, languages) {
+				matches[index] = true
+			}
+		}
+		return matches
+	}
+	return map[int64]bool{}
+}
+
+// Finds the documents matching the query, which may be a *SyntaxError if the
+// query is invalid. If language isn't empty only documents in that language
+// are found. The results are skipped until the offset and at most number
+// are returned, the order is stable so that pages don't overlap.
+func (e *QueryEngine) Find(text string, language string, offset int, number int) (*QueryResult, error) {
+	// Queries with enough results are hardly misspelled, so we don't even
+	// look for a correction
+	result, err := e.find(text, language, offset, number)
+	if err != nil || e.SpellChecker == nil || result.TotalDocs > CORRECTION_MAX_RESULTS {
+		return result, err
+	}
+
+	// Only suggest corrections that are worth it, a correct query with a few
+	// results doesn't need one
+	correction, ok, err := e.SpellChecker.CorrectQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return result, nil
+	}
+	corrected, err := e.find(correction, language, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if corrected.TotalDocs > 0 && corrected.TotalDocs > CORRECTION_RESULTS_RATIO*result.TotalDocs {
+		result.Correction = correction
+	}
+	return result, nil
+}
+
+func (e *QueryEngine) find(text string, language string, offset int, number int) (*QueryResult, error) {
+	node, err := Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return &QueryResult{Documents: []*model.Document{}, Scores: []float64{}, Snippets: []*Snippet{}}, nil
+	}
+
+	if language != "" {
+		code := NormalizeLanguage(language)
+		if code == "" {
+			return nil, &SyntaxError{Message: fmt.Sprintf("unknown language '%v', expected a code like 'en'", language)}
+		}
+		node = &AndNode{Children: []Node{node, &LanguageNode{Language: code}}}
+	}
+
+	// The languages are only loaded if the query filters by them or has
+	// stopwords
+	var languages map[int64]string
+	loadLanguages := func() (map[int64]string, error) {
+		if languages == nil {
+			var err error
+			languages, err = e.IndexStore.GetLanguages()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return languages, nil
+	}
+	if filtersLanguage(node) {
+		if _, err := loadLanguages(); err != nil {
+			return nil, err
+		}
+	}
+
+	docCount, avgLengths, err := e.IndexStore.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	termPostings := map[string][]store.Posting{}
+	for _, term := range allTerms(node) {
+		if _, ok := termPostings[term.key()]; ok {
+			continue
+		}
+		postings, err := e.termPostings(term, loadLanguages)
 		if err != nil {
 			return nil, err
 		}
+		termPostings[term.key()] = postings
+	}
+
+	indexRanks := map[int64]float64{}
+	for index := range evaluate(node, termPostings, languages) {
+		indexRanks[index] = 0
+	}
+
+	// Score the matching documents by all terms that aren't excluded, even
+	// if they are in the other branch of an OR.
+	pageRanks := map[int64]float64{}
+	termPositions := []map[int64]map[int][]int{}
+	scored := map[string]bool{}
+	for _, term := range positiveTerms(node) {
+		if scored[term.key()] {
+			continue
+		}
+		scored[term.key()] = true
+		postings := termPostings[term.key()]
+
+		frequencies := map[int64]float64{}
+		positions := map[int64]map[int][]int{}
+		for _, posting := range postings {
+			if _, ok := indexRanks[posting.Index]; !ok || posting.Frequency == 0 {
+				continue
+			}
+			frequencies[posting.Index] += e.fieldFrequency(posting, avgLengths[posting.Field])
+			pageRanks[posting.Index] = posting.Rank
+			if positions[posting.Index] == nil {
+				positions[posting.Index] = map[int][]int{}
+			}
+			positions[posting.Index][posting.Field] = posting.Positions
+		}
+		if len(positions) > 0 {
+			termPositions = append(termPositions, positions)
+		}
 
-		for i, index := range indecies {
-			if _, ok := indexRanks[index]; !ok {
-				indexRanks[index] = 0.0
+		// The document frequency must count all documents with the term,
+		// not only the matching ones
+		docFrequency := map[int64]bool{}
+		for _, posting := range postings {
+			if posting.Frequency > 0 {
+				docFrequency[posting.Index] = true
 			}
-			indexRanks[index] += frequencies[i]
 		}
+		wordIdf := idf(docCount, int64(len(docFrequency)))
+		for index, tf := range frequencies {
+			if tf > 0 {
+				indexRanks[index] += e.bm25(wordIdf, tf)
+			}
+		}
+	}
+
+	for index, score := range indexRanks {
+		indexRanks[index] = score * (1 + e.ProximityWeight*proximity(index, termPositions))
+	}
+
+	// Blend in the PageRank, the logarithm keeps a few very popular pages
+	// from dominating every query. Documents that weren't ranked yet, like
+	// the ones of a resumed crawl, count as average.
+	for index, score := range indexRanks {
+		pageRank := pageRanks[index]
+		if pageRank == 0 {
+			pageRank = 1
+		}
+		indexRanks[index] = score * (1 + e.RankWeight*math.Log1p(pageRank))
 	}
 
 	// FIXME: Well, the internet does have more than 2,147,483,647 pages
@@ -51,9 +424,22 @@ func (e *QueryEngine) Find(text string, number int) (*QueryResult, error) {
 		rankedDocs = append(rankedDocs, rankedIndex{index: k, rank: v})
 	}
 	sort.Slice(rankedDocs, func(i, j int) bool {
-		return rankedDocs[i].rank > rankedDocs[j].rank
+		if rankedDocs[i].rank != rankedDocs[j].rank {
+			return rankedDocs[i].rank > rankedDocs[j].rank
+		}
+		return rankedDocs[i].index < rankedDocs[j].index
 	})
 
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(rankedDocs) {
+		offset = len(rankedDocs)
+	}
+	rankedDocs = rankedDocs[offset:]
+	if number < 0 {
+		number = 0
+	}
 	if len(rankedDocs) > number {
 		rankedDocs = rankedDocs[:number]
 	}
@@ -67,6 +453,8 @@ func (e *QueryEngine) Find(text string, number int) (*QueryResult, error) {
 
 	return &QueryResult{
 		Documents: docs,
+		Scores:    fp.Map(rankedDocs, func(i rankedIndex) float64 { return i.rank }),
+		Snippets:  makeSnippets(positiveTerms(node), docs),
 		TotalDocs: totalDocs,
 	}, nil
 }
@@ -0,0 +1,312 @@
+package query
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/flofriday/websearch/model"
+	"github.com/flofriday/websearch/store"
+)
+
+// An index of the body text of a few documents, kept in memory.
+type memoryIndexStore struct {
+	postings  map[string][]store.Posting
+	lengths   map[int64]int64
+	languages map[int64]string
+}
+
+func newMemoryIndexStore(bodies map[int64]string) *memoryIndexStore {
+	languages := map[int64]string{}
+	for index := range bodies {
+		languages[index] = "en"
+	}
+	return newMultilingualIndexStore(bodies, languages)
+}
+
+func newMultilingualIndexStore(bodies map[int64]string, languages map[int64]string) *memoryIndexStore {
+	s := &memoryIndexStore{postings: map[string][]store.Posting{}, lengths: map[int64]int64{}, languages: languages}
+	for index, body := range bodies {
+		tokens := AnalyzerFor(languages[index]).Analyze(body)
+		positions := map[string][]int{}
+		for _, token := range tokens {
+			positions[token.Term] = append(positions[token.Term], token.Position)
+		}
+		s.lengths[index] = int64(len(tokens))
+		for term, termPositions := range positions {
+			s.postings[term] = append(s.postings[term], store.Posting{
+				Index:     index,
+				Field:     store.FIELD_BODY,
+				Frequency: int64(len(termPositions)),
+				Positions: termPositions,
+				Length:    int64(len(tokens)),
+				Language:  languages[index],
+			})
+		}
+	}
+	return s
+}
+
+func (s *memoryIndexStore) PutAllWords(index int64, language string, fields map[int]map[string][]int) error {
+	return nil
+}
+
+func (s *memoryIndexStore) ReplaceField(field int, documents map[int64]map[string][]int) error {
+	return nil
+}
+
+func (s *memoryIndexStore) PutRanks(ranks map[int64]float64) error {
+	for _, postings := range s.postings {
+		for i := range postings {
+			postings[i].Rank = ranks[postings[i].Index]
+		}
+	}
+	return nil
+}
+
+func (s *memoryIndexStore) GetLanguages() (map[int64]string, error) {
+	return s.languages, nil
+}
+
+func (s *memoryIndexStore) Get(word string) ([]store.Posting, error) {
+	return s.postings[word], nil
+}
+
+func (s *memoryIndexStore) Stats() (int64, map[int]float64, error) {
+	total := int64(0)
+	for _, length := range s.lengths {
+		total += length
+	}
+	avgLength := float64(total) / float64(len(s.lengths))
+	return int64(len(s.lengths)), map[int]float64{store.FIELD_BODY: avgLength}, nil
+}
+
+func (s *memoryIndexStore) Optimize() error {
+	return nil
+}
+
+type memoryDocumentStore struct {
+	bodies map[int64]string
+}
+
+func (s *memoryDocumentStore) Put(doc *model.Document) error {
+	return nil
+}
+
+func (s *memoryDocumentStore) Get(index int64) (*model.Document, error) {
+	body, ok := s.bodies[index]
+	if !ok {
+		return nil, nil
+	}
+	return &model.Document{Index: index, Language: "en", Body: body}, nil
+}
+
+func (s *memoryDocumentStore) GetAll(indexes []int64) ([]*model.Document, error) {
+	docs := []*model.Document{}
+	for _, index := range indexes {
+		doc, _ := s.Get(index)
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func (s *memoryDocumentStore) Count() (int64, error) {
+	return int64(len(s.bodies)), nil
+}
+
+func (s *memoryDocumentStore) GetFingerprints() ([]int64, []uint64, error) {
+	return nil, nil, nil
+}
+
+// A query engine that only ranks by BM25 of the body.
+func newTestEngine(bodies map[int64]string) *QueryEngine {
+	engine := NewQueryEngine(newMemoryIndexStore(bodies), &memoryDocumentStore{bodies: bodies})
+	engine.RankWeight = 0
+	engine.ProximityWeight = 0
+	return engine
+}
+
+func find(t *testing.T, engine *QueryEngine, text string) *QueryResult {
+	t.Helper()
+	result, err := engine.Find(text, "", 0, 10)
+	if err != nil {
+		t.Fatalf("unable to search for %q: %v", text, err)
+	}
+	return result
+}
+
+func indexes(result *QueryResult) []int64 {
+	indexes := []int64{}
+	for _, doc := range result.Documents {
+		indexes = append(indexes, doc.Index)
+	}
+	return indexes
+}
+
+func TestRareTermsOutrankCommonOnes(t *testing.T) {
+	engine := newTestEngine(map[int64]string{
+		1: "the kernel schedules processes",
+		2: "linux linux linux linux on the desktop",
+		3: "linux servers run the web",
+		4: "linux phones and the rest",
+		5: "linux everywhere for the win",
+	})
+
+	// One mention of a rare term is worth more than many of a term that is
+	// in almost every document
+	result := find(t, engine, "kernel OR linux")
+	got := indexes(result)
+	if len(got) != 5 || got[0] != 1 {
+		t.Errorf("expected document 1 first, got %v", got)
+	}
+
+	// Stopwords don't match every document and don't change the score
+	withStopword := find(t, engine, "the kernel")
+	withoutStopword := find(t, engine, "kernel")
+	if got := indexes(withStopword); len(got) != 1 || got[0] != 1 {
+		t.Errorf("expected only document 1, got %v", got)
+	}
+	if withStopword.Scores[0] != withoutStopword.Scores[0] {
+		t.Errorf("expected the stopword not to count, got %v and %v", withStopword.Scores, withoutStopword.Scores)
+	}
+}
+
+func TestIdfIsNeverNegative(t *testing.T) {
+	if got := idf(10, 10); got <= 0 {
+		t.Errorf("expected a positive idf for a term in every document, got %v", got)
+	}
+	if idf(1000, 1) <= idf(1000, 10) {
+		t.Errorf("expected rare terms to have a higher idf")
+	}
+}
+
+func TestTermFrequencySaturation(t *testing.T) {
+	bodies := map[int64]string{
+		1: "kernel kernel kernel kernel alpha",
+		2: "kernel bravo charlie delta echo",
+		3: "foxtrot golf hotel india juliett",
+	}
+
+	engine := newTestEngine(bodies)
+	result := find(t, engine, "kernel")
+	if got := indexes(result); got[0] != 1 || result.Scores[0] <= result.Scores[1] {
+		t.Errorf("expected more occurrences to score higher, got %v with %v", got, result.Scores)
+	}
+
+	// Without k1 only the presence of the term counts
+	engine.K1 = 0
+	result = find(t, engine, "kernel")
+	if result.Scores[0] != result.Scores[1] {
+		t.Errorf("expected equal scores with k1=0, got %v", result.Scores)
+	}
+
+	// A bigger k1 lets the frequency count for more
+	engine.K1 = 1.2
+	low := find(t, engine, "kernel").Scores
+	engine.K1 = 3
+	high := find(t, engine, "kernel").Scores
+	if high[0]/high[1] <= low[0]/low[1] {
+		t.Errorf("expected a bigger k1 to favor the frequency more, got %v and %v", low, high)
+	}
+}
+
+func TestLengthNormalization(t *testing.T) {
+	bodies := map[int64]string{
+		1: "kernel alpha",
+		2: "kernel bravo charlie delta echo foxtrot golf hotel india juliett",
+		3: "kilo lima mike",
+	}
+
+	engine := newTestEngine(bodies)
+	result := find(t, engine, "kernel")
+	if got := indexes(result); got[0] != 1 || result.Scores[0] <= result.Scores[1] {
+		t.Errorf("expected the shorter document to score higher, got %v with %v", got, result.Scores)
+	}
+
+	// Without b the length doesn't matter
+	engine.B = 0
+	result = find(t, engine, "kernel")
+	if result.Scores[0] != result.Scores[1] {
+		t.Errorf("expected equal scores with b=0, got %v", result.Scores)
+	}
+}
+
+func TestStopwordsOnlyMatchInTheirLanguage(t *testing.T) {
+	bodies := map[int64]string{
+		1: "the kernel schedules processes",
+		2: "the kernel and the war of the scheduler",
+		3: "der Kernel war schnell",
+		4: "ein anderer Text",
+	}
+	languages := map[int64]string{1: "en", 2: "en", 3: "de", 4: "de"}
+	engine := NewQueryEngine(newMultilingualIndexStore(bodies, languages), &memoryDocumentStore{bodies: bodies})
+
+	// "war" is a German stopword, but English documents must contain it
+	if got := indexes(find(t, engine, "kernel war")); len(got) != 2 || got[0] == 1 || got[1] == 1 {
+		t.Errorf("expected documents 2 and 3, got %v", got)
+	}
+
+	// "the" is an English stopword, which German documents must contain
+	if got := indexes(find(t, engine, "the kernel")); len(got) != 2 || got[0] == 3 || got[1] == 3 {
+		t.Errorf("expected documents 1 and 2, got %v", got)
+	}
+}
+
+func TestUnknownRanksCountAsAverage(t *testing.T) {
+	bodies := map[int64]string{
+		1: "kernel alpha",
+		2: "kernel bravo",
+		3: "kernel charlie",
+	}
+	engine := newTestEngine(bodies)
+	engine.RankWeight = DEFAULT_RANK_WEIGHT
+	engine.IndexStore.PutRanks(map[int64]float64{1: 0, 2: 1, 3: 0.5})
+
+	result := find(t, engine, "kernel")
+	if got := indexes(result); len(got) != 3 || got[2] != 3 {
+		t.Errorf("expected the below average document last, got %v", got)
+	}
+	if result.Scores[0] != result.Scores[1] {
+		t.Errorf("expected an unknown rank to score like an average one, got %v", result.Scores)
+	}
+}
+
+func TestBooleanQueries(t *testing.T) {
+	engine := newTestEngine(map[int64]string{
+		1: "linux kernel",
+		2: "linux windows",
+		3: "windows kernel",
+		4: "bsd",
+		5: "linux windows kernel",
+	})
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"linux kernel", []int64{1, 5}},
+		{"linux AND kernel", []int64{1, 5}},
+		{"linux OR bsd", []int64{1, 2, 4, 5}},
+		{"linux windows OR bsd", []int64{2, 4, 5}},
+		{"linux -windows", []int64{1}},
+		{"kernel NOT linux", []int64{3}},
+		{"kernel -linux -windows", []int64{}},
+		{"(linux OR bsd) -kernel", []int64{2, 4}},
+		{"kernel -(linux windows)", []int64{1, 3}},
+		{"kernel -(linux OR windows)", []int64{}},
+		{"linux --windows", []int64{2, 5}},
+		{`"linux kernel"`, []int64{1}},
+		{"linux -hurd", []int64{1, 2, 5}},
+		{"hurd OR bsd", []int64{4}},
+		{"hurd linux", []int64{}},
+	}
+
+	for _, test := range tests {
+		result := find(t, engine, test.query)
+		got := indexes(result)
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		if !reflect.DeepEqual(got, test.want) || result.TotalDocs != int64(len(test.want)) {
+			t.Errorf("%q found %v of %v documents, want %v", test.query, got, result.TotalDocs, test.want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package query
+
+import (
+	"strings"
+
+	"github.com/flofriday/websearch/fp"
+	"github.com/flofriday/websearch/model"
+)
+
+// The number of words in a snippet
+const SNIPPET_WORDS = 30
+
+// How many words are shown before the first match in a snippet
+const SNIPPET_CONTEXT = 6
+
+// A piece of the text of a snippet, the ones matching the query are
+// highlighted.
+type SnippetPart struct {
+	Text      string
+	Highlight bool
+}
+
+// A short part of a document that shows why it matched the query.
+type Snippet struct {
+	Parts []SnippetPart
+}
+
+func (s *Snippet) String() string {
+	var b strings.Builder
+	for _, part := range s.Parts {
+		b.WriteString(part.Text)
+	}
+	return b.String()
+}
+
+func (s *Snippet) add(text string, highlight bool) {
+	if text == "" {
+		return
+	}
+	// Merge with the previous part, so the number of parts stays small
+	if n := len(s.Parts); n > 0 && s.Parts[n-1].Highlight == highlight {
+		s.Parts[n-1].Text += text
+		return
+	}
+	s.Parts = append(s.Parts, SnippetPart{Text: text, Highlight: highlight})
+}
+
+// Returns the start of the window of SNIPPET_WORDS tokens with the most
+// different matching terms, and the most matches among those.
+func bestWindow(tokens []Token, matches []bool) (int, bool) {
+	counts := map[string]int{}
+	matchCount := 0
+	bestStart, bestScore := 0, 0
+
+	for end := 0; end < len(tokens); end++ {
+		if matches[end] {
+			counts[tokens[end].Term]++
+			matchCount++
+		}
+		start := end - SNIPPET_WORDS + 1
+		if start > 0 && matches[start-1] {
+			term := tokens[start-1].Term
+			counts[term]--
+			if counts[term] == 0 {
+				delete(counts, term)
+			}
+			matchCount--
+		}
+		if start < 0 {
+			start = 0
+		}
+
+		score := len(counts)*SNIPPET_WORDS + matchCount
+		if score > bestScore {
+			bestStart, bestScore = start, score
+		}
+	}
+	return bestStart, bestScore > 0
+}
+
+// Builds a snippet from the part of the text with the most query terms in
+// it. The terms must be analyzed with the analyzer of the document. Returns
+// nil if the text doesn't contain any of them.
+func makeSnippet(text string, analyzer *Analyzer, terms map[string]bool) *Snippet {
+	tokens := TokenizeSpans(text)
+	matches := make([]bool, len(tokens))
+	for _, token := range analyzer.Filter(tokens) {
+		if terms[token.Term] {
+			matches[token.Position] = true
+		}
+	}
+
+	start, ok := bestWindow(tokens, matches)
+	if !ok {
+		return nil
+	}
+
+	// Show a little context before the first match, as long as the last
+	// one stays in the snippet and the snippet doesn't get shorter than it
+	// could be
+	first, last := -1, -1
+	for i := start; i < len(tokens) && i < start+SNIPPET_WORDS; i++ {
+		if matches[i] {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	start = first - SNIPPET_CONTEXT
+	if last-start >= SNIPPET_WORDS {
+		start = last - SNIPPET_WORDS + 1
+	}
+	if start > len(tokens)-SNIPPET_WORDS {
+		start = len(tokens) - SNIPPET_WORDS
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + SNIPPET_WORDS
+	if end > len(tokens) {
+		end = len(tokens)
+	}
+
+	// Cut at spaces, so punctuation next to the words isn't cut off
+	textStart := tokens[start].Start
+	if i := strings.LastIndexByte(text[:textStart], ' '); i >= 0 {
+		textStart = i + 1
+	} else {
+		textStart = 0
+	}
+	textEnd := tokens[end-1].End
+	if i := strings.IndexByte(text[textEnd:], ' '); i >= 0 {
+		textEnd += i
+	} else {
+		textEnd = len(text)
+	}
+
+	snippet := &Snippet{}
+	if start > 0 {
+		snippet.add("… ", false)
+	}
+	offset := textStart
+	for i := start; i < end; i++ {
+		if !matches[i] {
+			continue
+		}
+		snippet.add(text[offset:tokens[i].Start], false)
+		snippet.add(text[tokens[i].Start:tokens[i].End], true)
+		offset = tokens[i].End
+	}
+	snippet.add(text[offset:textEnd], false)
+	if end < len(tokens) {
+		snippet.add(" …", false)
+	}
+	return snippet
+}
+
+// Builds a snippet for every document from the terms of the query. If a
+// document doesn't contain any of them in the body its description is used.
+func makeSnippets(terms []*TermNode, docs []*model.Document) []*Snippet {
+	analyzedTerms := map[*Analyzer]map[string]bool{}
+	snippets := make([]*Snippet, len(docs))
+	for i, doc := range docs {
+		if doc == nil {
+			continue
+		}
+
+		analyzer := AnalyzerFor(doc.Language)
+		if _, ok := analyzedTerms[analyzer]; !ok {
+			analyzedTerms[analyzer] = map[string]bool{}
+			for _, term := range terms {
+				tokens := fp.Map(term.Words, func(word string) Token { return Token{Term: word} })
+				for _, token := range analyzer.Filter(tokens) {
+					analyzedTerms[analyzer][token.Term] = true
+				}
+			}
+		}
+
+		snippets[i] = makeSnippet(doc.Body, analyzer, analyzedTerms[analyzer])
+		if snippets[i] == nil {
+			snippets[i] = &Snippet{Parts: []SnippetPart{{Text: doc.Description}}}
+		}
+	}
+	return snippets
+}
@@ -0,0 +1,89 @@
+package query
+
+import (
+	"strings"
+	"testing"
+)
+
+func snippetTerms(words ...string) map[string]bool {
+	terms := map[string]bool{}
+	for _, token := range AnalyzerFor("en").Analyze(strings.Join(words, " ")) {
+		terms[token.Term] = true
+	}
+	return terms
+}
+
+func highlights(snippet *Snippet) []string {
+	texts := []string{}
+	for _, part := range snippet.Parts {
+		if part.Highlight {
+			texts = append(texts, part.Text)
+		}
+	}
+	return texts
+}
+
+func TestSnippetOfAShortBodyIsTheWholeBody(t *testing.T) {
+	body := "The city never sleeps and neither do its servers, which all run the kernel in New York style. Linux home"
+	snippet := makeSnippet(body, AnalyzerFor("en"), snippetTerms("linux"))
+	if snippet == nil {
+		t.Fatal("expected a snippet")
+	}
+	if got := snippet.String(); got != body {
+		t.Errorf("expected the whole body, got %q", got)
+	}
+	if got := highlights(snippet); len(got) != 1 || got[0] != "Linux" {
+		t.Errorf("expected Linux to be highlighted, got %v", got)
+	}
+}
+
+func TestSnippetOfALongBody(t *testing.T) {
+	filler := strings.Repeat("lorem ipsum dolor sit amet ", 20)
+	body := filler + "the Linux kernel schedules processes. " + filler
+	snippet := makeSnippet(body, AnalyzerFor("en"), snippetTerms("linux", "kernel"))
+	if snippet == nil {
+		t.Fatal("expected a snippet")
+	}
+
+	text := snippet.String()
+	if !strings.HasPrefix(text, "… ") || !strings.HasSuffix(text, " …") {
+		t.Errorf("expected the snippet to be cut on both sides, got %q", text)
+	}
+	if words := len(strings.Fields(strings.Trim(text, "… "))); words != SNIPPET_WORDS {
+		t.Errorf("expected %v words, got %v in %q", SNIPPET_WORDS, words, text)
+	}
+	if !strings.Contains(text, "amet the Linux kernel schedules") {
+		t.Errorf("expected the matches with context, got %q", text)
+	}
+	if got := highlights(snippet); len(got) != 2 || got[0] != "Linux" || got[1] != "kernel" {
+		t.Errorf("expected Linux and kernel to be highlighted, got %v", got)
+	}
+
+	// The context before the first match is kept short
+	before, _, _ := strings.Cut(text, "Linux")
+	if words := len(strings.Fields(strings.TrimPrefix(before, "… "))); words != SNIPPET_CONTEXT {
+		t.Errorf("expected %v words before the first match, got %v", SNIPPET_CONTEXT, words)
+	}
+}
+
+func TestSnippetAtTheEndOfABodyIsFull(t *testing.T) {
+	body := strings.Repeat("lorem ipsum dolor sit amet ", 20) + "the end of Linux"
+	snippet := makeSnippet(body, AnalyzerFor("en"), snippetTerms("linux"))
+	if snippet == nil {
+		t.Fatal("expected a snippet")
+	}
+
+	text := snippet.String()
+	if !strings.HasPrefix(text, "… ") || !strings.HasSuffix(text, "Linux") {
+		t.Errorf("expected the snippet to end with the body, got %q", text)
+	}
+	if words := len(strings.Fields(strings.TrimPrefix(text, "… "))); words != SNIPPET_WORDS {
+		t.Errorf("expected %v words, got %v in %q", SNIPPET_WORDS, words, text)
+	}
+}
+
+func TestSnippetWithoutMatches(t *testing.T) {
+	if snippet := makeSnippet("nothing to see here", AnalyzerFor("en"), snippetTerms("linux")); snippet != nil {
+		t.Errorf("expected no snippet, got %q", snippet.String())
+	}
+}
@@ -0,0 +1,234 @@
+package query
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/flofriday/websearch/store"
+)
+
+// Words up to this length may only have a single typo, longer ones two
+const MAX_SINGLE_EDIT_LEN = 4
+
+// How many times more documents a correction must be in than the word
+// itself. Pages have typos too, so words in a few documents are still
+// corrected if a much more common one is close.
+const CORRECTION_FREQUENCY_RATIO = 10
+
+// A node of a BK-tree, the children are the words at each distance to the
+// word of the node.
+type bkNode struct {
+	word     []rune
+	children map[int]*bkNode
+}
+
+func (n *bkNode) insert(word []rune) {
+	for {
+		distance := editDistance(n.word, word)
+		if distance == 0 {
+			return
+		}
+		child, ok := n.children[distance]
+		if !ok {
+			n.children[distance] = &bkNode{word: word, children: map[int]*bkNode{}}
+			return
+		}
+		n = child
+	}
+}
+
+// Calls found for all words at most maxDistance edits away. The edit
+// distance is a metric, so by the triangle inequality only the children
+// within maxDistance of the distance to this node can contain them.
+func (n *bkNode) search(word []rune, maxDistance int, found func(word []rune, distance int)) {
+	distance := editDistance(n.word, word)
+	if distance <= maxDistance {
+		found(n.word, distance)
+	}
+	for childDistance, child := range n.children {
+		if childDistance >= distance-maxDistance && childDistance <= distance+maxDistance {
+			child.search(word, maxDistance, found)
+		}
+	}
+}
+
+// The Damerau-Levenshtein distance: the number of inserted, deleted,
+// substituted or swapped neighbouring characters. Unlike the simpler optimal
+// string alignment distance, characters can still be edited after they were
+// swapped, which makes it a metric the BK-tree can rely on.
+func editDistance(a []rune, b []rune) int {
+	// The last row each character was seen in
+	lastRow := map[rune]int{}
+	infinity := len(a) + len(b)
+
+	// Shifted by one, so the first row and column can hold the infinity
+	d := make([][]int, len(a)+2)
+	for i := range d {
+		d[i] = make([]int, len(b)+2)
+	}
+	d[0][0] = infinity
+	for i := 0; i <= len(a); i++ {
+		d[i+1][0] = infinity
+		d[i+1][1] = i
+	}
+	for j := 0; j <= len(b); j++ {
+		d[0][j+1] = infinity
+		d[1][j+1] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		// The last column in this row where the characters matched
+		lastMatch := 0
+		for j := 1; j <= len(b); j++ {
+			k := lastRow[b[j-1]]
+			l := lastMatch
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+				lastMatch = j
+			}
+			d[i+1][j+1] = minInt(
+				minInt(d[i][j]+cost, d[i+1][j]+1),
+				minInt(d[i][j+1]+1, d[k][l]+(i-k-1)+1+(j-l-1)),
+			)
+		}
+		lastRow[a[i-1]] = i
+	}
+	return d[len(a)+1][len(b)+1]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Corrects misspelled words with the terms of the index. The dictionary is
+// only built once the first correction is needed, as that takes a while for
+// large indexes.
+type SpellChecker struct {
+	suggestionStore store.SuggestionStore
+	root            *bkNode
+	frequencies     map[string]int64
+	loadOnce        sync.Once
+	loadErr         error
+}
+
+func NewSpellChecker(suggestionStore store.SuggestionStore) *SpellChecker {
+	return &SpellChecker{suggestionStore: suggestionStore}
+}
+
+// Builds the dictionary from all terms in the store, if that didn't happen
+// yet. Long running programs can call it upfront, so that the first search
+// doesn't have to wait.
+func (s *SpellChecker) Load() error {
+	s.loadOnce.Do(func() {
+		terms, frequencies, err := s.suggestionStore.GetAllTerms()
+		if err != nil {
+			s.loadErr = err
+			return
+		}
+
+		s.frequencies = map[string]int64{}
+		for i, term := range terms {
+			s.frequencies[term] = frequencies[i]
+			if s.root == nil {
+				s.root = &bkNode{word: []rune(term), children: map[int]*bkNode{}}
+				continue
+			}
+			s.root.insert([]rune(term))
+		}
+	})
+	return s.loadErr
+}
+
+// Returns the most common term with the fewest edits to the word, if it is
+// much more common than the word. Short words, stopwords and numbers are
+// never corrected. The dictionary must be loaded.
+func (s *SpellChecker) correct(word string) (string, bool) {
+	runes := []rune(word)
+	if s.root == nil || len(runes) < MIN_SUGGESTION_LEN || isAnyStopword(word) {
+		return "", false
+	}
+	for _, r := range runes {
+		if unicode.IsDigit(r) {
+			return "", false
+		}
+	}
+
+	maxDistance := 2
+	if len(runes) <= MAX_SINGLE_EDIT_LEN {
+		maxDistance = 1
+	}
+
+	best := ""
+	bestDistance := maxDistance + 1
+	bestFrequency := int64(0)
+	s.root.search(runes, maxDistance, func(candidate []rune, distance int) {
+		if distance == 0 {
+			return
+		}
+		term := string(candidate)
+		frequency := s.frequencies[term]
+		if frequency <= s.frequencies[word]*CORRECTION_FREQUENCY_RATIO {
+			return
+		}
+		if distance < bestDistance ||
+			(distance == bestDistance && frequency > bestFrequency) ||
+			(distance == bestDistance && frequency == bestFrequency && term < best) {
+			best, bestDistance, bestFrequency = term, distance, frequency
+		}
+	})
+	return best, best != ""
+}
+
+// Corrects the words of a query and leaves everything else, like operators,
+// fields and excluded words, as it is. Returns false if nothing was
+// corrected or the query can't be parsed.
+func (s *SpellChecker) CorrectQuery(text string) (string, bool, error) {
+	if err := s.Load(); err != nil {
+		return "", false, err
+	}
+
+	l := &lexer{text: text}
+	var b strings.Builder
+	offset := 0
+	corrected := false
+	excluded := false
+
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return "", false, nil
+		}
+		if tok.kind == TOKEN_EOF {
+			break
+		}
+		if tok.kind != TOKEN_TERM || excluded {
+			excluded = tok.kind == TOKEN_NOT
+			continue
+		}
+
+		// The term is at the end of the token, only followed by the quote of
+		// a phrase
+		termStart := tok.offset + strings.LastIndex(tok.text, tok.term)
+		for _, word := range TokenizeSpans(tok.term) {
+			correction, ok := s.correct(word.Term)
+			if !ok {
+				continue
+			}
+			b.WriteString(text[offset : termStart+word.Start])
+			b.WriteString(correction)
+			offset = termStart + word.End
+			corrected = true
+		}
+	}
+
+	if !corrected {
+		return "", false, nil
+	}
+	b.WriteString(text[offset:])
+	return b.String(), true, nil
+}
@@ -0,0 +1,144 @@
+package query
+
+import "testing"
+
+// A suggestion store with a fixed dictionary, only the terms are used.
+type memorySuggestionStore struct {
+	terms map[string]int64
+	// How often the whole dictionary was loaded
+	loads int
+}
+
+func (s *memorySuggestionStore) PutTerms(terms []string) error {
+	return nil
+}
+
+func (s *memorySuggestionStore) PutQuery(query string) error {
+	return nil
+}
+
+func (s *memorySuggestionStore) GetTerms(prefix string, number int) ([]string, error) {
+	return nil, nil
+}
+
+func (s *memorySuggestionStore) GetQueries(prefix string, number int) ([]string, error) {
+	return nil, nil
+}
+
+func (s *memorySuggestionStore) GetAllTerms() ([]string, []int64, error) {
+	s.loads++
+	terms := []string{}
+	frequencies := []int64{}
+	for term, frequency := range s.terms {
+		terms = append(terms, term)
+		frequencies = append(frequencies, frequency)
+	}
+	return terms, frequencies, nil
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"kernel", "kernel", 0},
+		{"", "abc", 3},
+		{"kernel", "kernal", 1},
+		{"linux", "lnux", 1},
+		{"linux", "liunx", 1},
+		{"kitten", "sitting", 3},
+		// The optimal string alignment distance would be 3 here
+		{"ca", "abc", 2},
+		{"größe", "grösse", 2},
+	}
+
+	for _, test := range tests {
+		if got := editDistance([]rune(test.a), []rune(test.b)); got != test.want {
+			t.Errorf("editDistance(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+		if got := editDistance([]rune(test.b), []rune(test.a)); got != test.want {
+			t.Errorf("editDistance(%q, %q) = %v, want %v", test.b, test.a, got, test.want)
+		}
+	}
+}
+
+func TestBKTreeFindsAllCandidates(t *testing.T) {
+	words := []string{"ac", "abc", "ca", "cab", "bca", "linux", "linus", "lynx", "kernel", "colonel"}
+	root := &bkNode{word: []rune(words[0]), children: map[int]*bkNode{}}
+	for _, word := range words[1:] {
+		root.insert([]rune(word))
+	}
+
+	for _, query := range []string{"ca", "abc", "linx", "kernal", "xyz"} {
+		for maxDistance := 0; maxDistance <= 3; maxDistance++ {
+			found := map[string]bool{}
+			root.search([]rune(query), maxDistance, func(word []rune, distance int) {
+				found[string(word)] = true
+			})
+			for _, word := range words {
+				want := editDistance([]rune(query), []rune(word)) <= maxDistance
+				if found[word] != want {
+					t.Errorf("search(%q, %v) found %q: %v, want %v", query, maxDistance, word, found[word], want)
+				}
+			}
+		}
+	}
+}
+
+func TestCorrectQuery(t *testing.T) {
+	checker := NewSpellChecker(&memorySuggestionStore{terms: map[string]int64{
+		"linux":  50,
+		"kernel": 40,
+		"module": 30,
+		"kernal": 1,
+	}})
+
+	tests := []struct {
+		query string
+		want  string
+		ok    bool
+	}{
+		{"linux kernel", "", false},
+		{"lnux", "linux", true},
+		{"Linux Kernal", "Linux kernel", true},
+		{`title:"lnux kernel" -modle`, `title:"linux kernel" -modle`, true},
+		{"lnux OR kernel lang:en", "linux OR kernel lang:en", true},
+		{"zzzzzz", "", false},
+		{`"unclosed`, "", false},
+	}
+
+	for _, test := range tests {
+		got, ok, err := checker.CorrectQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want || ok != test.ok {
+			t.Errorf("CorrectQuery(%q) = %q, %v, want %q, %v", test.query, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestOnlyQueriesWithFewResultsAreCorrected(t *testing.T) {
+	bodies := map[int64]string{}
+	for i := int64(0); i < CORRECTION_MAX_RESULTS+1; i++ {
+		bodies[i] = "linux kernel"
+	}
+	bodies[100] = "a kernal module"
+	suggestions := &memorySuggestionStore{terms: map[string]int64{"linux": 50, "kernel": 50, "kernal": 1, "module": 1}}
+	engine := newTestEngine(bodies)
+	engine.SpellChecker = NewSpellChecker(suggestions)
+
+	if result := find(t, engine, "linux"); result.Correction != "" || suggestions.loads != 0 {
+		t.Errorf("expected no correction and no dictionary, got %q after %v loads", result.Correction, suggestions.loads)
+	}
+
+	result := find(t, engine, "kernal")
+	if result.Correction != "kernel" {
+		t.Errorf("expected the correction kernel, got %q", result.Correction)
+	}
+	find(t, engine, "lnux")
+	if suggestions.loads != 1 {
+		t.Errorf("expected the dictionary to be loaded once, got %v loads", suggestions.loads)
+	}
+}
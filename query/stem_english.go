@@ -0,0 +1,359 @@
+package query
+
+import (
+	"strings"
+)
+
+// The Porter2 (Snowball) stemmer for English, as described at
+// https://snowballstem.org/algorithms/english/stemmer.html
+
+// Words that the algorithm would get wrong
+var englishExceptions = map[string]string{
+	"skies":  "sky",
+	"dying":  "die",
+	"lying":  "lie",
+	"tying":  "tie",
+	"idly":   "idl",
+	"gently": "gentl",
+	"ugly":   "ugli",
+	"early":  "earli",
+	"only":   "onli",
+	"singly": "singl",
+	"sky":    "sky",
+	"news":   "news",
+	"howe":   "howe",
+	"atlas":  "atlas",
+	"cosmos": "cosmos",
+	"bias":   "bias",
+	"andes":  "andes",
+}
+
+// Words that are left alone after step 1a
+var englishExceptions1a = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true,
+	"earring": true, "proceed": true, "exceed": true, "succeed": true,
+}
+
+func isEnglishVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	}
+	return false
+}
+
+// The stemmer works on runes, so words with other letters don't break it.
+type englishWord struct {
+	w  []rune
+	r1 int
+	r2 int
+}
+
+func (w *englishWord) hasSuffix(suffix string) bool {
+	s := []rune(suffix)
+	if len(s) > len(w.w) {
+		return false
+	}
+	return string(w.w[len(w.w)-len(s):]) == suffix
+}
+
+// Returns the longest of the suffixes the word ends with, or "".
+func (w *englishWord) longestSuffix(suffixes ...string) string {
+	longest := ""
+	for _, suffix := range suffixes {
+		if len(suffix) > len(longest) && w.hasSuffix(suffix) {
+			longest = suffix
+		}
+	}
+	return longest
+}
+
+// The start of the suffix, in runes.
+func (w *englishWord) suffixStart(suffix string) int {
+	return len(w.w) - len([]rune(suffix))
+}
+
+func (w *englishWord) replace(suffix string, replacement string) {
+	w.w = append(w.w[:w.suffixStart(suffix)], []rune(replacement)...)
+}
+
+func (w *englishWord) inR1(suffix string) bool {
+	return w.suffixStart(suffix) >= w.r1
+}
+
+func (w *englishWord) inR2(suffix string) bool {
+	return w.suffixStart(suffix) >= w.r2
+}
+
+func containsVowel(runes []rune) bool {
+	for _, r := range runes {
+		if isEnglishVowel(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// The region after the first non-vowel following a vowel, or the end.
+func regionAfter(runes []rune, start int, isVowel func(rune) bool) int {
+	for i := start + 1; i < len(runes); i++ {
+		if !isVowel(runes[i]) && isVowel(runes[i-1]) {
+			return i + 1
+		}
+	}
+	return len(runes)
+}
+
+// A short syllable is a vowel followed by a non-vowel other than w, x or Y
+// and preceded by a non-vowel, or a vowel at the beginning followed by a
+// non-vowel. The syllable ends at the end of the word.
+func (w *englishWord) endsWithShortSyllable() bool {
+	n := len(w.w)
+	if n == 2 {
+		return isEnglishVowel(w.w[0]) && !isEnglishVowel(w.w[1])
+	}
+	if n < 3 {
+		return false
+	}
+	last := w.w[n-1]
+	return !isEnglishVowel(w.w[n-3]) && isEnglishVowel(w.w[n-2]) &&
+		!isEnglishVowel(last) && last != 'w' && last != 'x' && last != 'Y'
+}
+
+func (w *englishWord) isShort() bool {
+	return w.r1 >= len(w.w) && w.endsWithShortSyllable()
+}
+
+func isDouble(runes []rune) bool {
+	n := len(runes)
+	if n < 2 || runes[n-1] != runes[n-2] {
+		return false
+	}
+	return strings.ContainsRune("bdfgmnprt", runes[n-1])
+}
+
+func isLiEnding(r rune) bool {
+	return strings.ContainsRune("cdeghkmnrt", r)
+}
+
+// Reduces an English word to its stem, so that "running", "runs" and "run"
+// all become "run". The word must be lowercase.
+func StemEnglish(word string) string {
+	if len([]rune(word)) <= 2 {
+		return word
+	}
+	if exception, ok := englishExceptions[word]; ok {
+		return exception
+	}
+
+	word = strings.TrimPrefix(word, "'")
+	w := &englishWord{w: []rune(word)}
+	if len(w.w) <= 2 {
+		return word
+	}
+
+	// Mark the y's that are consonants
+	for i, r := range w.w {
+		if r == 'y' && (i == 0 || isEnglishVowel(w.w[i-1])) {
+			w.w[i] = 'Y'
+		}
+	}
+
+	w.r1 = regionAfter(w.w, 0, isEnglishVowel)
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(string(w.w), prefix) {
+			w.r1 = len([]rune(prefix))
+			break
+		}
+	}
+	w.r2 = regionAfter(w.w, w.r1, isEnglishVowel)
+
+	englishStep0(w)
+	englishStep1a(w)
+	if englishExceptions1a[string(w.w)] {
+		return string(w.w)
+	}
+	englishStep1b(w)
+	englishStep1c(w)
+	englishStep2(w)
+	englishStep3(w)
+	englishStep4(w)
+	englishStep5(w)
+
+	return strings.ReplaceAll(string(w.w), "Y", "y")
+}
+
+func englishStep0(w *englishWord) {
+	if suffix := w.longestSuffix("'", "'s", "'s'"); suffix != "" {
+		w.replace(suffix, "")
+	}
+}
+
+func englishStep1a(w *englishWord) {
+	switch suffix := w.longestSuffix("sses", "ied", "ies", "s", "us", "ss"); suffix {
+	case "sses":
+		w.replace(suffix, "ss")
+	case "ied", "ies":
+		if w.suffixStart(suffix) > 1 {
+			w.replace(suffix, "i")
+		} else {
+			w.replace(suffix, "ie")
+		}
+	case "s":
+		// Only if there is a vowel before the letter before the s
+		start := w.suffixStart(suffix)
+		if start >= 1 && containsVowel(w.w[:start-1]) {
+			w.replace(suffix, "")
+		}
+	}
+}
+
+func englishStep1b(w *englishWord) {
+	suffix := w.longestSuffix("eed", "eedly", "ed", "edly", "ing", "ingly")
+	switch suffix {
+	case "":
+		return
+	case "eed", "eedly":
+		if w.inR1(suffix) {
+			w.replace(suffix, "ee")
+		}
+		return
+	}
+
+	if !containsVowel(w.w[:w.suffixStart(suffix)]) {
+		return
+	}
+	w.replace(suffix, "")
+
+	switch {
+	case w.hasSuffix("at") || w.hasSuffix("bl") || w.hasSuffix("iz"):
+		w.w = append(w.w, 'e')
+	case isDouble(w.w):
+		w.w = w.w[:len(w.w)-1]
+	case w.isShort():
+		w.w = append(w.w, 'e')
+	}
+}
+
+func englishStep1c(w *englishWord) {
+	n := len(w.w)
+	if n > 2 && (w.w[n-1] == 'y' || w.w[n-1] == 'Y') && !isEnglishVowel(w.w[n-2]) {
+		w.w[n-1] = 'i'
+	}
+}
+
+var englishStep2Suffixes = map[string]string{
+	"tional":  "tion",
+	"enci":    "ence",
+	"anci":    "ance",
+	"abli":    "able",
+	"entli":   "ent",
+	"izer":    "ize",
+	"ization": "ize",
+	"ational": "ate",
+	"ation":   "ate",
+	"ator":    "ate",
+	"alism":   "al",
+	"aliti":   "al",
+	"alli":    "al",
+	"fulness": "ful",
+	"ousli":   "ous",
+	"ousness": "ous",
+	"iveness": "ive",
+	"iviti":   "ive",
+	"biliti":  "ble",
+	"bli":     "ble",
+	"ogi":     "og",
+	"fulli":   "ful",
+	"lessli":  "less",
+	"li":      "",
+}
+
+func englishStep2(w *englishWord) {
+	suffix := ""
+	for candidate := range englishStep2Suffixes {
+		if len(candidate) > len(suffix) && w.hasSuffix(candidate) {
+			suffix = candidate
+		}
+	}
+	if suffix == "" || !w.inR1(suffix) {
+		return
+	}
+
+	start := w.suffixStart(suffix)
+	switch suffix {
+	case "ogi":
+		if start == 0 || w.w[start-1] != 'l' {
+			return
+		}
+	case "li":
+		if start == 0 || !isLiEnding(w.w[start-1]) {
+			return
+		}
+	}
+	w.replace(suffix, englishStep2Suffixes[suffix])
+}
+
+var englishStep3Suffixes = map[string]string{
+	"tional":  "tion",
+	"ational": "ate",
+	"alize":   "al",
+	"icate":   "ic",
+	"iciti":   "ic",
+	"ical":    "ic",
+	"ful":     "",
+	"ness":    "",
+	"ative":   "",
+}
+
+func englishStep3(w *englishWord) {
+	suffix := ""
+	for candidate := range englishStep3Suffixes {
+		if len(candidate) > len(suffix) && w.hasSuffix(candidate) {
+			suffix = candidate
+		}
+	}
+	if suffix == "" || !w.inR1(suffix) {
+		return
+	}
+	if suffix == "ative" && !w.inR2(suffix) {
+		return
+	}
+	w.replace(suffix, englishStep3Suffixes[suffix])
+}
+
+func englishStep4(w *englishWord) {
+	suffix := w.longestSuffix("al", "ance", "ence", "er", "ic", "able", "ible", "ant",
+		"ement", "ment", "ent", "ism", "ate", "iti", "ous", "ive", "ize", "ion")
+	if suffix == "" || !w.inR2(suffix) {
+		return
+	}
+	if suffix == "ion" {
+		start := w.suffixStart(suffix)
+		if start == 0 || (w.w[start-1] != 's' && w.w[start-1] != 't') {
+			return
+		}
+	}
+	w.replace(suffix, "")
+}
+
+func englishStep5(w *englishWord) {
+	switch {
+	case w.hasSuffix("e"):
+		if w.inR2("e") {
+			w.replace("e", "")
+			return
+		}
+		if w.inR1("e") {
+			// Check the syllable before the e
+			rest := &englishWord{w: w.w[:len(w.w)-1]}
+			if !rest.endsWithShortSyllable() {
+				w.replace("e", "")
+			}
+		}
+	case w.hasSuffix("l"):
+		if w.inR2("l") && len(w.w) >= 2 && w.w[len(w.w)-2] == 'l' {
+			w.replace("l", "")
+		}
+	}
+}
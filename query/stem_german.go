@@ -0,0 +1,137 @@
+package query
+
+import (
+	"strings"
+)
+
+// The Snowball stemmer for German, as described at
+// https://snowballstem.org/algorithms/german/stemmer.html
+
+func isGermanVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u', 'y', 'ä', 'ö', 'ü':
+		return true
+	}
+	return false
+}
+
+func isGermanSEnding(r rune) bool {
+	return strings.ContainsRune("bdfghklmnrt", r)
+}
+
+func isGermanStEnding(r rune) bool {
+	return strings.ContainsRune("bdfghklmnt", r)
+}
+
+// Reduces a German word to its stem, so that "Häuser" and "Haus" both
+// become "haus". The word must be lowercase.
+func StemGerman(word string) string {
+	word = strings.ReplaceAll(word, "ß", "ss")
+	w := &englishWord{w: []rune(word)}
+
+	// Mark the u's and y's between vowels, as they are consonants there
+	for i := 1; i < len(w.w)-1; i++ {
+		if !isGermanVowel(w.w[i-1]) || !isGermanVowel(w.w[i+1]) {
+			continue
+		}
+		switch w.w[i] {
+		case 'u':
+			w.w[i] = 'U'
+		case 'y':
+			w.w[i] = 'Y'
+		}
+	}
+
+	w.r1 = regionAfter(w.w, 0, isGermanVowel)
+	// The region before R1 must have at least three letters
+	if w.r1 < 3 {
+		w.r1 = 3
+	}
+	if w.r1 > len(w.w) {
+		w.r1 = len(w.w)
+	}
+	w.r2 = regionAfter(w.w, regionAfter(w.w, 0, isGermanVowel), isGermanVowel)
+
+	germanStep1(w)
+	germanStep2(w)
+	germanStep3(w)
+
+	stem := string(w.w)
+	stem = strings.NewReplacer("U", "u", "Y", "y", "ä", "a", "ö", "o", "ü", "u").Replace(stem)
+	return stem
+}
+
+func germanStep1(w *englishWord) {
+	suffix := w.longestSuffix("em", "ern", "er", "e", "en", "es", "s")
+	if suffix == "" || !w.inR1(suffix) {
+		return
+	}
+
+	switch suffix {
+	case "em", "ern", "er":
+		w.replace(suffix, "")
+	case "e", "en", "es":
+		w.replace(suffix, "")
+		if w.hasSuffix("niss") {
+			w.replace("s", "")
+		}
+	case "s":
+		start := w.suffixStart(suffix)
+		if start > 0 && isGermanSEnding(w.w[start-1]) {
+			w.replace(suffix, "")
+		}
+	}
+}
+
+func germanStep2(w *englishWord) {
+	suffix := w.longestSuffix("en", "er", "est", "st")
+	if suffix == "" || !w.inR1(suffix) {
+		return
+	}
+
+	if suffix == "st" {
+		// Must be preceded by a valid ending, itself preceded by at least
+		// three letters
+		start := w.suffixStart(suffix)
+		if start < 4 || !isGermanStEnding(w.w[start-1]) {
+			return
+		}
+	}
+	w.replace(suffix, "")
+}
+
+func germanStep3(w *englishWord) {
+	suffix := w.longestSuffix("end", "ung", "ig", "ik", "isch", "lich", "heit", "keit")
+	if suffix == "" || !w.inR2(suffix) {
+		return
+	}
+
+	precededBy := func(s string) bool {
+		rest := &englishWord{w: w.w[:w.suffixStart(suffix)]}
+		return rest.hasSuffix(s)
+	}
+
+	switch suffix {
+	case "end", "ung":
+		w.replace(suffix, "")
+		if w.hasSuffix("ig") && w.inR2("ig") && !w.hasSuffix("eig") {
+			w.replace("ig", "")
+		}
+	case "ig", "ik", "isch":
+		if !precededBy("e") {
+			w.replace(suffix, "")
+		}
+	case "lich", "heit":
+		w.replace(suffix, "")
+		if (w.hasSuffix("er") && w.inR1("er")) || (w.hasSuffix("en") && w.inR1("en")) {
+			w.replace(w.longestSuffix("er", "en"), "")
+		}
+	case "keit":
+		w.replace(suffix, "")
+		if w.hasSuffix("lich") && w.inR2("lich") {
+			w.replace("lich", "")
+		} else if w.hasSuffix("ig") && w.inR2("ig") {
+			w.replace("ig", "")
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package query
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Reads the pairs of words and their stems from a file in testdata.
+func readStems(t *testing.T, name string) map[string]string {
+	t.Helper()
+	file, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	stems := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word, stem, ok := strings.Cut(line, " ")
+		if !ok {
+			t.Fatalf("%v: invalid line %q", name, line)
+		}
+		stems[word] = stem
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return stems
+}
+
+func TestStemEnglish(t *testing.T) {
+	for word, want := range readStems(t, "stem_english.txt") {
+		if got := StemEnglish(word); got != want {
+			t.Errorf("StemEnglish(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestStemGerman(t *testing.T) {
+	for word, want := range readStems(t, "stem_german.txt") {
+		if got := StemGerman(word); got != want {
+			t.Errorf("StemGerman(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/flofriday/websearch/store"
+)
+
+// Shorter words are never suggested, they are typed faster than picked
+const MIN_SUGGESTION_LEN = 3
+
+type Suggester struct {
+	Store store.SuggestionStore
+}
+
+func NewSuggester(suggestionStore store.SuggestionStore) *Suggester {
+	return &Suggester{
+		Store: suggestionStore,
+	}
+}
+
+// Queries are stored and looked up in the same form, so "Linux  Kernel"
+// completes to "linux kernel module".
+func normalizeQuery(text string) string {
+	return Normalize(strings.Join(strings.Fields(text), " "))
+}
+
+// The terms of a document that can be suggested, every term only once.
+func SuggestionTerms(analyzer *Analyzer, texts ...string) []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	for _, text := range texts {
+		for _, term := range Tokenize(text) {
+			if seen[term] || utf8.RuneCountInString(term) < MIN_SUGGESTION_LEN || analyzer.IsStopword(term) {
+				continue
+			}
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Remembers a query that was searched for, so that it can be suggested.
+func (s *Suggester) Remember(text string) error {
+	text = normalizeQuery(text)
+	if text == "" {
+		return nil
+	}
+	return s.Store.PutQuery(text)
+}
+
+// Returns at most number completions of the text. Past queries come first,
+// followed by the indexed terms that complete the last word.
+func (s *Suggester) Suggest(text string, number int) ([]string, error) {
+	normalized := normalizeQuery(text)
+	if normalized == "" {
+		return []string{}, nil
+	}
+
+	// A trailing space means the last word is complete
+	if strings.HasSuffix(text, " ") {
+		normalized += " "
+	}
+
+	queries, err := s.Store.GetQueries(normalized, number)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []string{}
+	seen := map[string]bool{normalizeQuery(normalized): true}
+	add := func(suggestion string) {
+		if !seen[suggestion] && len(suggestions) < number {
+			seen[suggestion] = true
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	for _, query := range queries {
+		add(query)
+	}
+
+	start := strings.LastIndexFunc(normalized, unicode.IsSpace) + 1
+	head, word := normalized[:start], normalized[start:]
+	if word == "" {
+		return suggestions, nil
+	}
+
+	terms, err := s.Store.GetTerms(word, number)
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range terms {
+		add(head + term)
+	}
+	return suggestions, nil
+}
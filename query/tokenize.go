@@ -0,0 +1,148 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A word found in a text. Start and End are the byte offsets of the word in
+// the original text, so it can be highlighted.
+type Token struct {
+	Term  string
+	Start int
+	End   int
+	// The number of words before this one, which stays the same if some
+	// tokens are dropped
+	Position int
+}
+
+type charClass int
+
+const (
+	CLASS_OTHER charClass = iota
+	CLASS_LETTER
+	CLASS_DIGIT
+	// Katakana words are written without spaces but the characters of a
+	// word belong together
+	CLASS_KATAKANA
+	// Every Han or Hiragana character is a word of its own
+	CLASS_IDEOGRAPH
+	// Joins letters and digits, like the underscore
+	CLASS_CONNECTOR
+	// Invisible characters like the soft hyphen, which are ignored
+	CLASS_FORMAT
+)
+
+func classify(r rune) charClass {
+	switch {
+	case unicode.In(r, unicode.Han, unicode.Hiragana):
+		return CLASS_IDEOGRAPH
+	case unicode.Is(unicode.Katakana, r) || r == 'ー':
+		return CLASS_KATAKANA
+	case unicode.IsLetter(r) || unicode.IsMark(r):
+		return CLASS_LETTER
+	case unicode.IsDigit(r):
+		return CLASS_DIGIT
+	case unicode.Is(unicode.Pc, r):
+		return CLASS_CONNECTOR
+	case unicode.Is(unicode.Cf, r):
+		return CLASS_FORMAT
+	}
+	return CLASS_OTHER
+}
+
+// Punctuation that doesn't end a word if there are letters on both sides,
+// like in "don't" or "U.S.A".
+func isMidLetter(r rune) bool {
+	return r == '\'' || r == '’' || r == '.' || r == '·' || r == '‧'
+}
+
+// Punctuation that doesn't end a word if there are digits on both sides,
+// like in "3.14" or "1,000".
+func isMidNumber(r rune) bool {
+	return r == '.' || r == ',' || r == ';' || r == '\'' || r == '’'
+}
+
+// Whether a word that ends with class can continue with next.
+func continuesWord(class charClass, next charClass) bool {
+	switch class {
+	case CLASS_KATAKANA:
+		return next == CLASS_KATAKANA || next == CLASS_CONNECTOR
+	case CLASS_LETTER, CLASS_DIGIT, CLASS_CONNECTOR:
+		return next == CLASS_LETTER || next == CLASS_DIGIT || next == CLASS_CONNECTOR
+	}
+	return false
+}
+
+// Splits the text into words, loosely following the word boundaries of
+// Unicode (UAX #29). Punctuation around words is dropped, hyphenated words
+// are split into their parts and Chinese or Japanese characters are words on
+// their own, so that searching for them works like a phrase search.
+// The terms of the tokens are normalized.
+func TokenizeSpans(text string) []Token {
+	tokens := []Token{}
+	var term strings.Builder
+
+	offset := 0
+	for offset < len(text) {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		class := classify(r)
+		if class == CLASS_OTHER || class == CLASS_FORMAT {
+			offset += size
+			continue
+		}
+
+		start := offset
+		term.Reset()
+		term.WriteRune(r)
+		offset += size
+
+		if class != CLASS_IDEOGRAPH {
+			last := class
+			for offset < len(text) {
+				r, size := utf8.DecodeRuneInString(text[offset:])
+				next := classify(r)
+				if next == CLASS_FORMAT {
+					offset += size
+					continue
+				}
+				if continuesWord(last, next) {
+					term.WriteRune(r)
+					offset += size
+					last = next
+					continue
+				}
+
+				// Some punctuation is part of a word if the same kind of
+				// character follows it
+				after, afterSize := utf8.DecodeRuneInString(text[offset+size:])
+				afterClass := classify(after)
+				isMid := (last == CLASS_LETTER && afterClass == CLASS_LETTER && isMidLetter(r)) ||
+					(last == CLASS_DIGIT && afterClass == CLASS_DIGIT && isMidNumber(r))
+				if !isMid {
+					break
+				}
+				term.WriteRune(r)
+				term.WriteRune(after)
+				offset += size + afterSize
+			}
+		}
+
+		if normalized := Normalize(term.String()); normalized != "" {
+			tokens = append(tokens, Token{Term: normalized, Start: start, End: offset, Position: len(tokens)})
+		}
+	}
+	return tokens
+}
+
+// Splits the text into normalized terms, this must be used for the documents
+// and the queries alike.
+func Tokenize(text string) []string {
+	tokens := TokenizeSpans(text)
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = token.Term
+	}
+	return terms
+}
@@ -0,0 +1,86 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+var tokenizeTests = []struct {
+	text string
+	want []string
+}{
+	{"", []string{}},
+	{"  ,.;!  ", []string{}},
+	{"Linux,", []string{"linux"}},
+	{"(Linux)", []string{"linux"}},
+	{"Linux.", []string{"linux"}},
+	{"'quoted'", []string{"quoted"}},
+	{"end. Next", []string{"end", "next"}},
+	{"don't", []string{"don't"}},
+	{"don’t", []string{"don't"}},
+	{"U.S.A", []string{"u.s.a"}},
+	{"U.S.A.", []string{"u.s.a"}},
+	{"a...b", []string{"a", "b"}},
+	{"3.14", []string{"3.14"}},
+	{"1,000", []string{"1,000"}},
+	{"1.2.3", []string{"1.2.3"}},
+	{"v1.0 42nd", []string{"v1.0", "42nd"}},
+	{"e-mail", []string{"e", "mail"}},
+	{"snake_case x86_64", []string{"snake_case", "x86_64"}},
+	{"foo@bar.com", []string{"foo", "bar.com"}},
+	{"C++", []string{"c"}},
+	{"Größe ÜBER café", []string{"größe", "über", "café"}},
+	// Every Han and Hiragana character is a word, Katakana words stay whole
+	{"日本語のページ", []string{"日", "本", "語", "の", "ページ"}},
+	{"カタカナ語", []string{"カタカナ", "語"}},
+	{"Linux日本", []string{"linux", "日", "本"}},
+	// Soft hyphens are invisible
+	{"Sil\u00adben\u00adtren\u00adnung", []string{"silbentrennung"}},
+	{"\u00adLinux\u00ad", []string{"linux"}},
+}
+
+func TestTokenize(t *testing.T) {
+	for _, test := range tokenizeTests {
+		if got := Tokenize(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+// Snippets highlight the words by their offsets, so they must point at the
+// word in the original text.
+func TestTokenOffsets(t *testing.T) {
+	withoutFormat := func(text string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.Is(unicode.Cf, r) {
+				return -1
+			}
+			return r
+		}, text)
+	}
+
+	texts := []string{"The (Linux) kernel, don’t you know, costs 1,000 U.S. dollars.\n\tSee e-mail: Größe über 日本語のページ."}
+	for _, test := range tokenizeTests {
+		texts = append(texts, test.text)
+	}
+
+	for _, text := range texts {
+		tokens := TokenizeSpans(text)
+		end := 0
+		for i, token := range tokens {
+			if token.Position != i {
+				t.Errorf("%q: token %v has position %v", text, i, token.Position)
+			}
+			if token.Start < end || token.End <= token.Start || token.End > len(text) {
+				t.Errorf("%q: token %q has offsets %v-%v after %v", text, token.Term, token.Start, token.End, end)
+				continue
+			}
+			end = token.End
+			if source := withoutFormat(text[token.Start:token.End]); Normalize(source) != token.Term {
+				t.Errorf("%q: token %q points at %q", text, token.Term, source)
+			}
+		}
+	}
+}
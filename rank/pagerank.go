@@ -0,0 +1,85 @@
+// PageRank over the link graph of the crawled documents.
+package rank
+
+import (
+	"math"
+)
+
+type Options struct {
+	// The probability that the random surfer follows a link instead of
+	// jumping to a random document
+	Damping float64
+	// The iteration stops once the ranks change less than this (L1 norm)
+	Epsilon       float64
+	MaxIterations int
+}
+
+// Computes the PageRank of every node. The edges are given as pairs of
+// sources[i] -> targets[i]. The ranks are scaled so that the average document
+// has a rank of 1, which makes them independent of the size of the index.
+// Also returns the number of iterations that were needed.
+func PageRank(nodes []int64, sources []int64, targets []int64, opts Options) (map[int64]float64, int) {
+	n := len(nodes)
+	if n == 0 {
+		return map[int64]float64{}, 0
+	}
+
+	// Map the ids to dense positions so we can work on slices
+	position := make(map[int64]int, n)
+	for i, node := range nodes {
+		position[node] = i
+	}
+
+	outDegree := make([]int, n)
+	incoming := make([][]int, n)
+	for i := range sources {
+		from, ok1 := position[sources[i]]
+		to, ok2 := position[targets[i]]
+		if !ok1 || !ok2 {
+			continue
+		}
+		outDegree[from]++
+		incoming[to] = append(incoming[to], from)
+	}
+
+	ranks := make([]float64, n)
+	next := make([]float64, n)
+	for i := range ranks {
+		ranks[i] = 1 / float64(n)
+	}
+
+	iteration := 0
+	for iteration < opts.MaxIterations {
+		iteration++
+
+		// Documents without links spread their rank over all documents
+		dangling := 0.0
+		for i, rank := range ranks {
+			if outDegree[i] == 0 {
+				dangling += rank
+			}
+		}
+		base := (1-opts.Damping)/float64(n) + opts.Damping*dangling/float64(n)
+
+		diff := 0.0
+		for i := range next {
+			sum := 0.0
+			for _, from := range incoming[i] {
+				sum += ranks[from] / float64(outDegree[from])
+			}
+			next[i] = base + opts.Damping*sum
+			diff += math.Abs(next[i] - ranks[i])
+		}
+
+		ranks, next = next, ranks
+		if diff < opts.Epsilon {
+			break
+		}
+	}
+
+	result := make(map[int64]float64, n)
+	for i, node := range nodes {
+		result[node] = ranks[i] * float64(n)
+	}
+	return result, iteration
+}
@@ -0,0 +1,125 @@
+package robots
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// RFC 9309 requires crawlers to parse at least 500 KiB, everything after that
+// can be ignored.
+const MAX_ROBOTS_SIZE = 500 * 1024
+
+// How long a robots.txt that couldn't be fetched disallows everything before
+// we try again, so that a single timeout doesn't lock us out of a host.
+const RETRY_DELAY = time.Minute
+
+type cacheEntry struct {
+	ready chan struct{}
+	rules *Rules
+	err   error
+	// Only failed fetches expire
+	expires time.Time
+}
+
+// Fetches and caches the robots.txt rules of every host the crawler visits.
+// It is safe for concurrent use and every robots.txt is only downloaded once,
+// even if many downloaders ask for the same host at the same time.
+type Cache struct {
+	client     *http.Client
+	userAgent  string
+	retryDelay time.Duration
+	entries    map[string]*cacheEntry
+	lock       sync.Mutex
+}
+
+func NewCache(client *http.Client, userAgent string) *Cache {
+	return &Cache{
+		client:     client,
+		userAgent:  userAgent,
+		retryDelay: RETRY_DELAY,
+		entries:    map[string]*cacheEntry{},
+	}
+}
+
+// Checks whether the robots.txt of the host allows us to crawl the url.
+// If the robots.txt could not be fetched, everything is disallowed and the
+// reason is returned as error, until it is fetched again after RETRY_DELAY.
+func (c *Cache) Allowed(link *url.URL) (bool, error) {
+	rules, err := c.rulesFor(link)
+	return rules.Allowed(c.userAgent, link), err
+}
+
+// The crawl delay the host asks us to respect.
+func (c *Cache) CrawlDelay(link *url.URL) time.Duration {
+	rules, _ := c.rulesFor(link)
+	return rules.CrawlDelay(c.userAgent)
+}
+
+func (c *Cache) rulesFor(link *url.URL) (*Rules, error) {
+	key := link.Scheme + "://" + link.Host
+
+	c.lock.Lock()
+	entry, ok := c.entries[key]
+	if ok && entry.expired(time.Now()) {
+		ok = false
+	}
+	if !ok {
+		entry = &cacheEntry{ready: make(chan struct{})}
+		c.entries[key] = entry
+	}
+	c.lock.Unlock()
+
+	if ok {
+		<-entry.ready
+		return entry.rules, entry.err
+	}
+
+	entry.rules, entry.err = c.fetch(key)
+	if entry.err != nil {
+		entry.expires = time.Now().Add(c.retryDelay)
+	}
+	close(entry.ready)
+	return entry.rules, entry.err
+}
+
+// Whether the entry must be fetched again. Entries that are still being
+// fetched never are.
+func (e *cacheEntry) expired(now time.Time) bool {
+	select {
+	case <-e.ready:
+		return !e.expires.IsZero() && !now.Before(e.expires)
+	default:
+		return false
+	}
+}
+
+func (c *Cache) fetch(origin string) (*Rules, error) {
+	req, err := http.NewRequest(http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return DisallowAll(), err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// The site is unreachable, so we assume a complete disallow, which
+		// the downloader would fail on anyway.
+		return DisallowAll(), err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return Parse(io.LimitReader(resp.Body, MAX_ROBOTS_SIZE)), nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		// No robots.txt means no restrictions
+		return AllowAll(), nil
+	default:
+		// Server errors mean we should stay away for now
+		return DisallowAll(), fmt.Errorf("robots.txt of %v returned status %v", origin, resp.StatusCode)
+	}
+}
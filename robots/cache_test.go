@@ -0,0 +1,143 @@
+package robots
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, handler http.HandlerFunc) (*Cache, *url.URL) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	base, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCache(server.Client(), AGENT), base
+}
+
+func checkCacheAllowed(t *testing.T, cache *Cache, base *url.URL, path string, want bool, wantErr bool) {
+	t.Helper()
+	got, err := cache.Allowed(base.ResolveReference(&url.URL{Path: path}))
+	if got != want {
+		t.Errorf("Allowed(%q) = %v, want %v", path, got, want)
+	}
+	if (err != nil) != wantErr {
+		t.Errorf("Allowed(%q) returned the error %v, expected one: %v", path, err, wantErr)
+	}
+}
+
+func TestCacheParsesRobotsTxt(t *testing.T) {
+	cache, base := newTestCache(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("User-Agent") != AGENT {
+			t.Errorf("robots.txt requested with the user agent %q", r.Header.Get("User-Agent"))
+		}
+		w.Write([]byte("User-Agent: *\nDisallow: /private\n"))
+	})
+
+	checkCacheAllowed(t, cache, base, "/public", true, false)
+	checkCacheAllowed(t, cache, base, "/private/file", false, false)
+}
+
+func TestCacheMissingRobotsTxtAllowsAll(t *testing.T) {
+	cache, base := newTestCache(t, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	checkCacheAllowed(t, cache, base, "/", true, false)
+	checkCacheAllowed(t, cache, base, "/anything", true, false)
+}
+
+func TestCacheServerErrorDisallowsAll(t *testing.T) {
+	cache, base := newTestCache(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	checkCacheAllowed(t, cache, base, "/", false, true)
+	checkCacheAllowed(t, cache, base, "/anything", false, true)
+}
+
+func TestCacheFollowsRedirects(t *testing.T) {
+	cache, base := newTestCache(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			http.Redirect(w, r, "/moved/robots.txt", http.StatusMovedPermanently)
+		case "/moved/robots.txt":
+			w.Write([]byte("User-Agent: *\nDisallow: /private\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	checkCacheAllowed(t, cache, base, "/public", true, false)
+	checkCacheAllowed(t, cache, base, "/private", false, false)
+}
+
+func TestCacheRedirectToMissingAllowsAll(t *testing.T) {
+	cache, base := newTestCache(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.Redirect(w, r, "/gone", http.StatusFound)
+			return
+		}
+		http.NotFound(w, r)
+	})
+
+	checkCacheAllowed(t, cache, base, "/private", true, false)
+}
+
+func TestCacheFetchesOnce(t *testing.T) {
+	var requests int32
+	cache, base := newTestCache(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte("User-Agent: *\nCrawl-delay: 1\n"))
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Allowed(base)
+		}()
+	}
+	wg.Wait()
+	cache.CrawlDelay(base)
+
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("robots.txt was requested %v times, want 1", got)
+	}
+}
+
+func TestCacheRetriesFailedFetches(t *testing.T) {
+	var requests int32
+	cache, base := newTestCache(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("User-Agent: *\nDisallow: /private\n"))
+	})
+
+	// Until the retry delay passed the failure sticks
+	cache.retryDelay = 100 * time.Millisecond
+	checkCacheAllowed(t, cache, base, "/public", false, true)
+	checkCacheAllowed(t, cache, base, "/public", false, true)
+
+	time.Sleep(cache.retryDelay)
+	checkCacheAllowed(t, cache, base, "/public", true, false)
+	checkCacheAllowed(t, cache, base, "/private", false, false)
+
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("robots.txt was requested %v times, want 2", got)
+	}
+}
@@ -0,0 +1,226 @@
+// A parser for the robots exclusion protocol (robots.txt) as described in
+// RFC 9309, including the widely used Crawl-delay extension.
+package robots
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+// The parsed content of a single robots.txt file.
+type Rules struct {
+	groups []*group
+}
+
+// Rules that don't restrict the crawler at all, used when a site has no
+// robots.txt.
+func AllowAll() *Rules {
+	return &Rules{}
+}
+
+// Rules that forbid everything, used when the robots.txt of a site could not
+// be reached.
+func DisallowAll() *Rules {
+	return &Rules{
+		groups: []*group{{
+			agents: []string{"*"},
+			rules:  []rule{{allow: false, pattern: "/"}},
+		}},
+	}
+}
+
+// Parses a robots.txt file. The parser is lenient and just ignores lines it
+// does not understand, like most crawlers do.
+func Parse(r io.Reader) *Rules {
+	rules := &Rules{}
+	var current *group
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive user-agent lines share the same group
+			if current == nil || !lastWasAgent {
+				current = &group{}
+				rules.groups = append(rules.groups, current)
+			}
+			if value == "*" {
+				current.agents = append(current.agents, value)
+			} else if token := productToken(value); token != "" {
+				current.agents = append(current.agents, token)
+			}
+			lastWasAgent = true
+			continue
+
+		case "allow", "disallow":
+			// An empty disallow means everything is allowed, which is the
+			// same as having no rule at all.
+			if current != nil && value != "" {
+				current.rules = append(current.rules, rule{
+					allow:   key == "allow",
+					pattern: value,
+				})
+			}
+
+		case "crawl-delay":
+			seconds, err := strconv.ParseFloat(value, 64)
+			if current != nil && err == nil && seconds >= 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+		lastWasAgent = false
+	}
+
+	return rules
+}
+
+// Returns the product token of a user agent, which is "websearch" for
+// "websearch/1.0 (+https://…)", in lower case.
+func productToken(agent string) string {
+	end := strings.IndexFunc(agent, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_' || r == '-')
+	})
+	if end < 0 {
+		end = len(agent)
+	}
+	return strings.ToLower(agent[:end])
+}
+
+func hasAgent(g *group, agent string) bool {
+	for _, a := range g.agents {
+		if a == agent {
+			return true
+		}
+	}
+	return false
+}
+
+// Find the groups that apply to the user agent. Groups match if their agent
+// is the product token of ours, ignoring the case, and the wildcard group is
+// only used if no other group matches.
+func (r *Rules) groupsFor(agent string) []*group {
+	token := productToken(agent)
+
+	var matching []*group
+	var wildcard []*group
+	for _, g := range r.groups {
+		if token != "" && hasAgent(g, token) {
+			matching = append(matching, g)
+		} else if hasAgent(g, "*") {
+			wildcard = append(wildcard, g)
+		}
+	}
+
+	if matching != nil {
+		return matching
+	}
+	return wildcard
+}
+
+// Checks whether the agent may crawl the url.
+func (r *Rules) Allowed(agent string, link *url.URL) bool {
+	path := link.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if link.RawQuery != "" {
+		path += "?" + link.RawQuery
+	}
+
+	// The robots.txt itself is always allowed
+	if path == "/robots.txt" {
+		return true
+	}
+
+	// The longest matching rule wins and if an allow and disallow rule are
+	// equally long the allow rule wins.
+	allowed := true
+	bestLen := -1
+	for _, g := range r.groupsFor(agent) {
+		for _, rule := range g.rules {
+			if !matches(rule.pattern, path) {
+				continue
+			}
+			if len(rule.pattern) > bestLen || (len(rule.pattern) == bestLen && rule.allow) {
+				bestLen = len(rule.pattern)
+				allowed = rule.allow
+			}
+		}
+	}
+	return allowed
+}
+
+// The delay the agent should wait between two requests, zero if the site
+// didn't specify any.
+func (r *Rules) CrawlDelay(agent string) time.Duration {
+	var delay time.Duration
+	for _, g := range r.groupsFor(agent) {
+		if g.crawlDelay > delay {
+			delay = g.crawlDelay
+		}
+	}
+	return delay
+}
+
+// Matches a path against a robots.txt pattern. A pattern matches any path
+// that starts with it, `*` matches any sequence of characters and a trailing
+// `$` anchors the pattern at the end of the path.
+func matches(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	// Classic wildcard matching with backtracking to the last star
+	p, s := 0, 0
+	star, mark := -1, 0
+	for s < len(path) {
+		if p < len(pattern) && pattern[p] == '*' {
+			star = p
+			mark = s
+			p++
+		} else if p < len(pattern) && pattern[p] == path[s] {
+			p++
+			s++
+		} else if p == len(pattern) && !anchored {
+			return true
+		} else if star >= 0 {
+			p = star + 1
+			mark++
+			s = mark
+		} else {
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,218 @@
+package robots
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const AGENT = "websearch/1.0 (+https://github.com/flofriday/websearch)"
+
+func allowed(t *testing.T, rules *Rules, agent string, raw string) bool {
+	t.Helper()
+	link, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("unable to parse %q: %v", raw, err)
+	}
+	return rules.Allowed(agent, link)
+}
+
+type allowedTest struct {
+	path string
+	want bool
+}
+
+func checkAllowed(t *testing.T, robotsTxt string, agent string, tests []allowedTest) {
+	t.Helper()
+	rules := Parse(strings.NewReader(robotsTxt))
+	for _, test := range tests {
+		if got := allowed(t, rules, agent, "https://example.com"+test.path); got != test.want {
+			t.Errorf("Allowed(%q) = %v, want %v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestLongestMatchWins(t *testing.T) {
+	// The example of RFC 9309 section 2.2.2
+	checkAllowed(t, `
+User-Agent: *
+Allow: /example/page/
+Disallow: /example/page/disallowed.gif
+Disallow: /example/
+`, AGENT, []allowedTest{
+		{"/", true},
+		{"/example/", false},
+		{"/example/other.html", false},
+		{"/example/page/", true},
+		{"/example/page/index.html", true},
+		{"/example/page/disallowed.gif", false},
+	})
+}
+
+func TestAllowWinsTies(t *testing.T) {
+	checkAllowed(t, `
+User-Agent: *
+Disallow: /folder
+Allow: /folder
+Disallow: /page.html
+Allow: /page.html
+`, AGENT, []allowedTest{
+		{"/folder", true},
+		{"/folder/sub", true},
+		{"/page.html", true},
+	})
+}
+
+func TestWildcards(t *testing.T) {
+	// The examples of RFC 9309 section 2.2.3
+	checkAllowed(t, `
+User-Agent: *
+Disallow: /*.gif$
+Disallow: /private*/secret
+Allow: /publications/
+Disallow: /*?session=
+`, AGENT, []allowedTest{
+		{"/image.gif", false},
+		{"/deep/path/image.gif", false},
+		{"/image.gif?size=large", true},
+		{"/image.gifs", true},
+		{"/private/secret", false},
+		{"/private-stuff/my/secret", false},
+		{"/private/public", true},
+		{"/publications/", true},
+		{"/search?session=42", false},
+		{"/search?q=linux", true},
+	})
+}
+
+func TestEndAnchor(t *testing.T) {
+	checkAllowed(t, `
+User-Agent: *
+Disallow: /$
+Disallow: /exact$
+`, AGENT, []allowedTest{
+		{"", false},
+		{"/", false},
+		{"/index.html", true},
+		{"/exact", false},
+		{"/exact/", true},
+		{"/exactly", true},
+	})
+}
+
+func TestEmptyDisallowAllowsEverything(t *testing.T) {
+	checkAllowed(t, `
+User-Agent: *
+Disallow:
+`, AGENT, []allowedTest{
+		{"/", true},
+		{"/anything", true},
+	})
+}
+
+func TestRobotsTxtIsAlwaysAllowed(t *testing.T) {
+	checkAllowed(t, `
+User-Agent: *
+Disallow: /
+`, AGENT, []allowedTest{
+		{"/robots.txt", true},
+		{"/index.html", false},
+	})
+}
+
+func TestGroups(t *testing.T) {
+	// Consecutive user-agent lines share a group, the most specific agent
+	// wins over the wildcard and groups of the same agent are merged
+	robotsTxt := `
+User-Agent: *
+Disallow: /
+
+User-Agent: otherbot
+User-Agent: WebSearch
+Disallow: /private
+Crawl-delay: 2
+
+User-Agent: badbot
+Disallow: /
+
+user-agent: websearch
+disallow: /tmp # comments are ignored
+`
+	checkAllowed(t, robotsTxt, AGENT, []allowedTest{
+		{"/", true},
+		{"/private", false},
+		{"/tmp/file", false},
+		{"/public", true},
+	})
+	checkAllowed(t, robotsTxt, "otherbot/2.0", []allowedTest{
+		{"/", true},
+		{"/private", false},
+		{"/tmp/file", true},
+	})
+	checkAllowed(t, robotsTxt, "somebot/1.0", []allowedTest{
+		{"/", false},
+		{"/public", false},
+	})
+
+	rules := Parse(strings.NewReader(robotsTxt))
+	if got := rules.CrawlDelay(AGENT); got != 2*time.Second {
+		t.Errorf("CrawlDelay() = %v, want 2s", got)
+	}
+	if got := rules.CrawlDelay("somebot/1.0"); got != 0 {
+		t.Errorf("CrawlDelay() = %v, want 0", got)
+	}
+}
+
+func TestRulesBeforeAnyAgentAreIgnored(t *testing.T) {
+	checkAllowed(t, `
+Disallow: /
+User-Agent: *
+Disallow: /private
+`, AGENT, []allowedTest{
+		{"/", true},
+		{"/private", false},
+	})
+}
+
+func TestAgentsMatchTheProductToken(t *testing.T) {
+	robotsTxt := `
+User-Agent:
+Disallow: /empty
+
+User-Agent: web
+Disallow: /prefix
+
+User-Agent: *
+Disallow: /wildcard
+`
+	// Neither an empty agent nor a prefix of our name are meant for us
+	checkAllowed(t, robotsTxt, AGENT, []allowedTest{
+		{"/empty", true},
+		{"/prefix", true},
+		{"/wildcard", false},
+	})
+
+	checkAllowed(t, `
+User-Agent: WebSearch/2.0
+Disallow: /versioned
+
+User-Agent: *
+Disallow: /wildcard
+`, AGENT, []allowedTest{
+		{"/versioned", false},
+		{"/wildcard", true},
+	})
+
+	checkAllowed(t, `
+User-Agent: *
+User-Agent: websearch
+Disallow: /shared
+`, AGENT, []allowedTest{
+		{"/shared", false},
+	})
+
+	if got := productToken(AGENT); got != "websearch" {
+		t.Errorf("productToken(%q) = %q, want websearch", AGENT, got)
+	}
+}
@@ -0,0 +1,38 @@
+// Statistics for the status log of a crawl.
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Counts events per reason, like why urls were dropped or downloads failed.
+// The zero value is ready to use and it is safe for concurrent use.
+type Counter[K ~string] struct {
+	counts map[K]int64
+	lock   sync.Mutex
+}
+
+func (c *Counter[K]) Add(reason K) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.counts == nil {
+		c.counts = map[K]int64{}
+	}
+	c.counts[reason]++
+}
+
+// Formats the counts like "robots=2 status=5", sorted by reason.
+func (c *Counter[K]) String() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	parts := []string{}
+	for reason, count := range c.counts {
+		parts = append(parts, fmt.Sprintf("%v=%v", reason, count))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
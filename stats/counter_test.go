@@ -0,0 +1,34 @@
+package stats
+
+import (
+	"sync"
+	"testing"
+)
+
+type reason string
+
+func TestCounter(t *testing.T) {
+	var counter Counter[reason]
+	if got := counter.String(); got != "" {
+		t.Errorf("expected an empty summary, got %q", got)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				counter.Add("status")
+			} else {
+				counter.Add("robots")
+			}
+		}(i)
+	}
+	wg.Wait()
+	counter.Add("network")
+
+	if got := counter.String(); got != "network=1 robots=5 status=5" {
+		t.Errorf("unexpected summary %q", got)
+	}
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+)
+
+// Texts are compressed before they are stored, as they are large and rarely
+// read.
+func compressText(text string) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write([]byte(text)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressText(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", nil
+	}
+	reader := flate.NewReader(bytes.NewReader(data))
+	defer reader.Close()
+	text, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
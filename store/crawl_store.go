@@ -0,0 +1,40 @@
+package store
+
+import "strings"
+
+// The state of an url in the crawl, later states overrule earlier ones. The
+// exception is URL_INDEXED which is never overruled, an url that failed can
+// still be indexed under a redirect or canonical url.
+const (
+	URL_DISCOVERED = iota
+	URL_REQUESTED
+	URL_INDEXED
+	// The download failed or the document couldn't be indexed, it won't be
+	// requested again
+	URL_FAILED
+)
+
+type CrawlURL struct {
+	Url string
+	// Index and Depth are negative if they are unknown
+	Index int64
+	Depth int
+	State int
+}
+
+// Remembers the urls of a crawl, so that it can be resumed later.
+type CrawlStore interface {
+	PutURL(*CrawlURL) error
+	PutURLs([]*CrawlURL) error
+	GetAll() ([]*CrawlURL, error)
+}
+
+// Returns the key under which the page of a canonical url is known. Most
+// sites serve the same pages over http and https, so both schemes share a
+// key.
+func URLKey(canonical string) string {
+	if rest, ok := strings.CutPrefix(canonical, "http://"); ok {
+		return "https://" + rest
+	}
+	return canonical
+}
@@ -7,4 +7,5 @@ type DocumentStore interface {
 	Get(index int64) (*model.Document, error)
 	GetAll(index []int64) ([]*model.Document, error)
 	Count() (int64, error)
+	GetFingerprints() ([]int64, []uint64, error)
 }
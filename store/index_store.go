@@ -1,9 +1,48 @@
 package store
 
+// The parts of a document that are indexed separately, so they can be
+// weighted differently when ranking.
+const (
+	FIELD_BODY = iota
+	// The text of the links other documents point to this one with
+	FIELD_ANCHOR
+	FIELD_TITLE
+	// All <h1> to <h6>
+	FIELD_HEADING
+	// The words in the path of the url
+	FIELD_URL
+	// The <meta name="description">
+	FIELD_DESCRIPTION
+)
+
+// A field of a document containing a word together with the statistics
+// needed for ranking.
+type Posting struct {
+	Index int64
+	Field int
+	// How often the word occurs in the field
+	Frequency int64
+	// Where the word occurs in the field, counted in words
+	Positions []int
+	// The number of words in the field
+	Length int64
+	// The PageRank of the document, 1 is average and 0 means unknown
+	Rank float64
+	// The language of the document, empty if unknown
+	Language string
+}
+
 type IndexStore interface {
-	PutWord(index int64, word string, frequency float64) error
-	PutAllWords(index int64, words map[string]float64) error
-	// PutRank(index int64, rank int64) error
-	Get(word string) ([]int64, []float64, error)
+	// Fields map to the positions of every word in them
+	PutAllWords(index int64, language string, fields map[int]map[string][]int) error
+	// Replaces a field in all documents, which is needed for fields that
+	// can only be computed after the crawl, like the anchor text.
+	ReplaceField(field int, documents map[int64]map[string][]int) error
+	PutRanks(ranks map[int64]float64) error
+	// The language of every document
+	GetLanguages() (map[int64]string, error)
+	Get(word string) ([]Posting, error)
+	// The number of indexed documents and the average length of every field
+	Stats() (int64, map[int]float64, error)
 	Optimize() error
 }
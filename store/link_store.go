@@ -0,0 +1,11 @@
+package store
+
+// Stores the links between documents, which form the graph for PageRank.
+type LinkStore interface {
+	// texts[i] is the anchor text of all links to targets[i]
+	PutLinks(source int64, targets []string, texts []string) error
+	// All documents and the links between them as pairs of source and target
+	GetGraph() ([]int64, []int64, []int64, error)
+	// The anchor text other documents link to each document with
+	GetAnchors() (map[int64][]string, error)
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Whether the table has the column, which a table created by an older
+// version might lack.
+func hasColumn(db *sql.DB, table string, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%v)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, primaryKey int
+		var name, columnType string
+		var defaultValue sql.NullString
+
+		err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey)
+		if err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// Adds a column to a table that was created by an older version, because
+// CREATE TABLE IF NOT EXISTS leaves existing tables as they are. The
+// definition needs a default if the column is scanned into a non-pointer.
+func addColumn(db *sql.DB, table string, column string, definition string) error {
+	ok, err := hasColumn(db, table, column)
+	if err != nil || ok {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %v ADD COLUMN %v %v", table, column, definition))
+	return err
+}
+
+// Adds the key column to a table of urls created by an older version and
+// fills it with the URLKey of every url.
+func addKeyColumn(db *sql.DB, table string, urlColumn string) error {
+	ok, err := hasColumn(db, table, "key")
+	if err != nil || ok {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %v ADD COLUMN key TEXT", table))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	rows, err := tx.Query(fmt.Sprintf("SELECT rowid, %v FROM %v", urlColumn, table))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	rowids := []int64{}
+	keys := []string{}
+	for rows.Next() {
+		var rowid int64
+		var link string
+		if err := rows.Scan(&rowid, &link); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return err
+		}
+		rowids = append(rowids, rowid)
+		keys = append(keys, URLKey(link))
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf("UPDATE %v SET key = ? WHERE rowid = ?", table))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for i, rowid := range rowids {
+		if _, err := stmt.Exec(keys[i], rowid); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Positions are stored as the varint encoded differences to the previous
+// position, which keeps them small as most are only a few words apart.
+func encodePositions(positions []int) []byte {
+	buf := make([]byte, 0, len(positions)*2)
+	last := 0
+	for _, position := range positions {
+		buf = binary.AppendUvarint(buf, uint64(position-last))
+		last = position
+	}
+	return buf
+}
+
+func decodePositions(buf []byte) ([]int, error) {
+	positions := []int{}
+	last := 0
+	for len(buf) > 0 {
+		delta, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return nil, errors.New("corrupt positions")
+		}
+		buf = buf[n:]
+		last += int(delta)
+		positions = append(positions, last)
+	}
+	return positions, nil
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type SQLCrawlStore struct {
+	db         *sql.DB
+	putStmt    *sql.Stmt
+	getAllStmt *sql.Stmt
+}
+
+func NewSQLCrawlStore(db *sql.DB) (*SQLCrawlStore, error) {
+	store := &SQLCrawlStore{
+		db: db,
+	}
+
+	// Create tables if they don't exist
+	err := store.createTables()
+	if err != nil {
+		return nil, err
+	}
+
+	store.putStmt, err = db.Prepare(putURLQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	store.getAllStmt, err = db.Prepare("SELECT url, id, depth, state FROM crawl_urls ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// An url never goes back to an earlier state and keeps its id once it was
+// requested.
+var putURLQuery = fmt.Sprintf(`
+	INSERT INTO crawl_urls (url, key, id, depth, state) VALUES (?1, ?2, ?3, ?4, ?5)
+	ON CONFLICT (url) DO UPDATE SET
+		id = CASE WHEN excluded.id >= 0 THEN excluded.id ELSE id END,
+		depth = CASE WHEN excluded.depth >= 0 THEN excluded.depth ELSE depth END,
+		state = CASE WHEN state = %[1]v OR excluded.state = %[1]v THEN %[1]v ELSE MAX(state, excluded.state) END
+	`, URL_INDEXED)
+
+func (s *SQLCrawlStore) createTables() error {
+	// Create crawl_urls table if it doesn't exist
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS crawl_urls (
+		url TEXT PRIMARY KEY,
+		key TEXT,
+		id INTEGER,
+		depth INTEGER,
+		state INTEGER
+	);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// The key was added later, links are resolved with it
+	err = addKeyColumn(s.db, "crawl_urls", "url")
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("CREATE INDEX IF NOT EXISTS crawl_urls_key ON crawl_urls (key)")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLCrawlStore) PutURL(crawlURL *CrawlURL) error {
+	_, err := s.putStmt.Exec(crawlURL.Url, URLKey(crawlURL.Url), crawlURL.Index, crawlURL.Depth, crawlURL.State)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLCrawlStore) PutURLs(crawlURLs []*CrawlURL) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt := tx.Stmt(s.putStmt)
+	defer stmt.Close()
+
+	for _, crawlURL := range crawlURLs {
+		_, err := stmt.Exec(crawlURL.Url, URLKey(crawlURL.Url), crawlURL.Index, crawlURL.Depth, crawlURL.State)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLCrawlStore) GetAll() ([]*CrawlURL, error) {
+	rows, err := s.getAllStmt.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var crawlURLs []*CrawlURL
+
+	for rows.Next() {
+		crawlURL := &CrawlURL{}
+		err := rows.Scan(&crawlURL.Url, &crawlURL.Index, &crawlURL.Depth, &crawlURL.State)
+		if err != nil {
+			return nil, err
+		}
+
+		crawlURLs = append(crawlURLs, crawlURL)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return crawlURLs, nil
+}
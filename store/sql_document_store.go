@@ -27,12 +27,12 @@ func NewSQLDocumentStore(db *sql.DB) (*SQLDocumentStore, error) {
 		return nil, err
 	}
 
-	store.putStmt, err = db.Prepare("INSERT INTO documents (id, title, description, url, icon) VALUES (?, ?, ?, ?, ?)")
+	store.putStmt, err = db.Prepare("INSERT INTO documents (id, title, description, url, icon, fingerprint, language, body) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return nil, err
 	}
 
-	store.getStmt, err = db.Prepare("SELECT title, description, url, icon FROM documents WHERE id = ?")
+	store.getStmt, err = db.Prepare("SELECT title, description, url, icon, language, body FROM documents WHERE id = ?")
 	if err != nil {
 		return nil, err
 	}
@@ -52,12 +52,29 @@ func (s *SQLDocumentStore) createTables() error {
 		title TEXT,
 		description TEXT,
 		url TEXT,
-		icon TEXT
+		icon TEXT,
+		fingerprint INTEGER,
+		language TEXT,
+		body BLOB
 	);`)
 	if err != nil {
 		return err
 	}
 
+	// Columns that were added later
+	err = addColumn(s.db, "documents", "fingerprint", "INTEGER DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = addColumn(s.db, "documents", "language", "TEXT DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	err = addColumn(s.db, "documents", "body", "BLOB")
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -66,8 +83,13 @@ func (s *SQLDocumentStore) Put(doc *model.Document) error {
 	if doc.Icon != nil {
 		icon = doc.Icon.String()
 	}
+	body, err := compressText(doc.Body)
+	if err != nil {
+		return err
+	}
 	// FIXME: Prepared statements are the way to go here
-	_, err := s.putStmt.Exec(doc.Index, doc.Title, doc.Description, doc.Url.String(), icon)
+	// SQLite only knows signed integers
+	_, err = s.putStmt.Exec(doc.Index, doc.Title, doc.Description, doc.Url.String(), icon, int64(doc.Fingerprint), doc.Language, body)
 	if err != nil {
 		return err
 	}
@@ -78,10 +100,11 @@ func (s *SQLDocumentStore) Put(doc *model.Document) error {
 func (s *SQLDocumentStore) Get(index int64) (*model.Document, error) {
 	row := s.getStmt.QueryRow(index)
 
-	doc := &model.Document{}
+	doc := &model.Document{Index: index}
 	var urlStr, iconStr string
+	var body []byte
 
-	err := row.Scan(&doc.Title, &doc.Description, &urlStr, &iconStr)
+	err := row.Scan(&doc.Title, &doc.Description, &urlStr, &iconStr, &doc.Language, &body)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil // Document not found
@@ -101,6 +124,11 @@ func (s *SQLDocumentStore) Get(index int64) (*model.Document, error) {
 	}
 	doc.Icon = iconObj
 
+	doc.Body, err = decompressText(body)
+	if err != nil {
+		return nil, err
+	}
+
 	return doc, nil
 }
 
@@ -161,3 +189,33 @@ func (s *SQLDocumentStore) Count() (int64, error) {
 
 	return count, nil
 }
+
+func (s *SQLDocumentStore) GetFingerprints() ([]int64, []uint64, error) {
+	rows, err := s.db.Query("SELECT id, fingerprint FROM documents")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var indexes []int64
+	var fingerprints []uint64
+
+	for rows.Next() {
+		var index int64
+		var fingerprint int64
+
+		err := rows.Scan(&index, &fingerprint)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		indexes = append(indexes, index)
+		fingerprints = append(fingerprints, uint64(fingerprint))
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return indexes, fingerprints, nil
+}
@@ -5,9 +5,10 @@ import (
 )
 
 type SQLIndexStore struct {
-	db      *sql.DB
-	putStmt *sql.Stmt
-	getStmt *sql.Stmt
+	db        *sql.DB
+	getStmt   *sql.Stmt
+	countStmt *sql.Stmt
+	statsStmt *sql.Stmt
 }
 
 func NewSQLIndexStore(db *sql.DB) (*SQLIndexStore, error) {
@@ -21,8 +22,26 @@ func NewSQLIndexStore(db *sql.DB) (*SQLIndexStore, error) {
 		return nil, err
 	}
 
-	store.putStmt, err = db.Prepare("INSERT INTO index_words (id, word, frequency) VALUES (?, ?, ?)")
-	store.getStmt, err = db.Prepare("SELECT id, frequency FROM index_words WHERE word = ?")
+	store.getStmt, err = db.Prepare(`
+	SELECT w.id, w.field, w.frequency, w.positions, l.length, d.rank, d.language
+	FROM index_words w
+	JOIN index_lengths l ON l.id = w.id AND l.field = w.field
+	JOIN index_documents d ON d.id = w.id
+	WHERE w.word = ?
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	store.countStmt, err = db.Prepare("SELECT COUNT(*) FROM index_documents")
+	if err != nil {
+		return nil, err
+	}
+
+	store.statsStmt, err = db.Prepare("SELECT field, AVG(length) FROM index_lengths GROUP BY field")
+	if err != nil {
+		return nil, err
+	}
 
 	return store, nil
 }
@@ -32,9 +51,36 @@ func (s *SQLIndexStore) createTables() error {
 	_, err := s.db.Exec(`
 	CREATE TABLE IF NOT EXISTS index_words (
 		id INTEGER,
+		field INTEGER,
 		word TEXT,
-		frequency FLOAT,
-		PRIMARY KEY(id, word)
+		frequency INTEGER,
+		positions BLOB,
+		PRIMARY KEY(id, field, word)
+	);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create index_lengths table if it doesn't exist
+	_, err = s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS index_lengths (
+		id INTEGER,
+		field INTEGER,
+		length INTEGER,
+		PRIMARY KEY(id, field)
+	);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create index_documents table if it doesn't exist
+	_, err = s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS index_documents (
+		id INTEGER PRIMARY KEY,
+		language TEXT,
+		rank FLOAT DEFAULT 0
 	);
 	`)
 	if err != nil {
@@ -56,31 +102,116 @@ func (s *SQLIndexStore) Optimize() error {
 	return nil
 }
 
-func (s *SQLIndexStore) PutWord(index int64, word string, frequency float64) error {
-	_, err := s.db.Exec("", index, word, frequency)
+// Inserts the words of the fields inside the transaction
+func putFields(tx *sql.Tx, index int64, fields map[int]map[string][]int) error {
+	lengthStmt, err := tx.Prepare("INSERT INTO index_lengths (id, field, length) VALUES (?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer lengthStmt.Close()
+
+	wordStmt, err := tx.Prepare("INSERT INTO index_words (id, field, word, frequency, positions) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer wordStmt.Close()
+
+	for field, words := range fields {
+		length := int64(0)
+		for word, positions := range words {
+			frequency := int64(len(positions))
+			_, err := wordStmt.Exec(index, field, word, frequency, encodePositions(positions))
+			if err != nil {
+				return err
+			}
+			length += frequency
+		}
+
+		_, err := lengthStmt.Exec(index, field, length)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLIndexStore) PutAllWords(index int64, language string, fields map[int]map[string][]int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO index_documents (id, language) VALUES (?, ?)", index, language)
 	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = putFields(tx, index, fields)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
 		return err
 	}
 
 	return nil
 }
 
-func (s *SQLIndexStore) PutAllWords(index int64, words map[string]float64) error {
+func (s *SQLIndexStore) ReplaceField(field int, documents map[int64]map[string][]int) error {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	//stmt := tx.Stmt(s.putStmt)
-	stmt, err := tx.Prepare("INSERT INTO index_words (id, word, frequency) VALUES (?, ?, ?)")
+	_, err = tx.Exec("DELETE FROM index_words WHERE field = ?", field)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM index_lengths WHERE field = ?", field)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for index, words := range documents {
+		err = putFields(tx, index, map[int]map[string][]int{field: words})
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLIndexStore) PutRanks(ranks map[int64]float64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("UPDATE index_documents SET rank = ? WHERE id = ?")
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 	defer stmt.Close()
 
-	for word, frequency := range words {
-		_, err := stmt.Exec(index, word, frequency)
+	for index, rank := range ranks {
+		_, err := stmt.Exec(rank, index)
 		if err != nil {
 			tx.Rollback()
 			return err
@@ -96,32 +227,95 @@ func (s *SQLIndexStore) PutAllWords(index int64, words map[string]float64) error
 	return nil
 }
 
-func (s *SQLIndexStore) Get(word string) ([]int64, []float64, error) {
+func (s *SQLIndexStore) GetLanguages() (map[int64]string, error) {
+	rows, err := s.db.Query("SELECT id, language FROM index_documents")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	languages := map[int64]string{}
+	for rows.Next() {
+		var index int64
+		var language string
+
+		err := rows.Scan(&index, &language)
+		if err != nil {
+			return nil, err
+		}
+
+		languages[index] = language
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return languages, nil
+}
+
+func (s *SQLIndexStore) Get(word string) ([]Posting, error) {
 	rows, err := s.getStmt.Query(word)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	defer rows.Close()
 
-	var indexes []int64
-	var frequencies []float64
+	var postings []Posting
+
+	for rows.Next() {
+		var posting Posting
+		var positions []byte
+
+		err := rows.Scan(&posting.Index, &posting.Field, &posting.Frequency, &positions, &posting.Length, &posting.Rank, &posting.Language)
+		if err != nil {
+			return nil, err
+		}
+
+		posting.Positions, err = decodePositions(positions)
+		if err != nil {
+			return nil, err
+		}
+
+		postings = append(postings, posting)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return postings, nil
+}
+
+func (s *SQLIndexStore) Stats() (int64, map[int]float64, error) {
+	var count int64
+	err := s.countStmt.QueryRow().Scan(&count)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rows, err := s.statsStmt.Query()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
 
+	avgLengths := map[int]float64{}
 	for rows.Next() {
-		var indexID int64
-		var frequency float64
+		var field int
+		var avgLength float64
 
-		err := rows.Scan(&indexID, &frequency)
+		err := rows.Scan(&field, &avgLength)
 		if err != nil {
-			return nil, nil, err
+			return 0, nil, err
 		}
 
-		indexes = append(indexes, indexID)
-		frequencies = append(frequencies, frequency)
+		avgLengths[field] = avgLength
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, nil, err
+		return 0, nil, err
 	}
 
-	return indexes, frequencies, nil
+	return count, avgLengths, nil
 }
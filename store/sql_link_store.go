@@ -0,0 +1,177 @@
+package store
+
+import (
+	"database/sql"
+)
+
+type SQLLinkStore struct {
+	db *sql.DB
+}
+
+func NewSQLLinkStore(db *sql.DB) (*SQLLinkStore, error) {
+	store := &SQLLinkStore{
+		db: db,
+	}
+
+	// Create tables if they don't exist
+	err := store.createTables()
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (s *SQLLinkStore) createTables() error {
+	// Create links table if it doesn't exist
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS links (
+		source INTEGER,
+		target TEXT,
+		key TEXT,
+		text TEXT,
+		PRIMARY KEY(source, target)
+	);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// The anchor text and the key were added later
+	err = addColumn(s.db, "links", "text", "TEXT DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	err = addKeyColumn(s.db, "links", "target")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLLinkStore) PutLinks(source int64, targets []string, texts []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO links (source, target, key, text) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for i, target := range targets {
+		_, err := stmt.Exec(source, target, URLKey(target), texts[i])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLLinkStore) getNodes() ([]int64, error) {
+	rows, err := s.db.Query("SELECT id FROM documents")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []int64
+	for rows.Next() {
+		var node int64
+		if err := rows.Scan(&node); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	return nodes, rows.Err()
+}
+
+func (s *SQLLinkStore) GetGraph() ([]int64, []int64, []int64, error) {
+	nodes, err := s.getNodes()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// The target urls are resolved to documents with the crawl state, which
+	// also knows the urls that redirected to a document. The keys match
+	// links to http urls with documents indexed under https and vice versa.
+	rows, err := s.db.Query(`
+	SELECT DISTINCT l.source, c.id
+	FROM links l
+	JOIN crawl_urls c ON c.key = l.key
+	JOIN documents d ON d.id = c.id
+	WHERE c.state = ? AND l.source != c.id
+	`, URL_INDEXED)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer rows.Close()
+
+	var sources []int64
+	var targets []int64
+
+	for rows.Next() {
+		var source, target int64
+
+		err := rows.Scan(&source, &target)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		sources = append(sources, source)
+		targets = append(targets, target)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return nodes, sources, targets, nil
+}
+
+func (s *SQLLinkStore) GetAnchors() (map[int64][]string, error) {
+	// Links of a document to itself (like "back to top") don't describe it
+	rows, err := s.db.Query(`
+	SELECT DISTINCT c.id, l.source, l.text
+	FROM links l
+	JOIN crawl_urls c ON c.key = l.key
+	JOIN documents d ON d.id = c.id
+	WHERE c.state = ? AND l.source != c.id AND l.text != ''
+	`, URL_INDEXED)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	anchors := map[int64][]string{}
+	for rows.Next() {
+		var target, source int64
+		var text string
+
+		err := rows.Scan(&target, &source, &text)
+		if err != nil {
+			return nil, err
+		}
+
+		anchors[target] = append(anchors[target], text)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return anchors, nil
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"database/sql"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/flofriday/websearch/model"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func putDocument(t *testing.T, documentStore *SQLDocumentStore, index int64, link string) {
+	t.Helper()
+	uri, _ := url.Parse(link)
+	if err := documentStore.Put(&model.Document{Index: index, Url: uri}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLinksMatchDocumentsOfTheOtherScheme(t *testing.T) {
+	db := openTestDB(t)
+	documentStore, err := NewSQLDocumentStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawlStore, err := NewSQLCrawlStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkStore, err := NewSQLLinkStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	putDocument(t, documentStore, 1, "https://example.com/a")
+	putDocument(t, documentStore, 2, "http://example.com/b")
+	err = crawlStore.PutURLs([]*CrawlURL{
+		{Url: "https://example.com/a", Index: 1, Depth: 0, State: URL_INDEXED},
+		{Url: "http://example.com/b", Index: 2, Depth: 1, State: URL_INDEXED},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := linkStore.PutLinks(1, []string{"https://example.com/b"}, []string{"to b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := linkStore.PutLinks(2, []string{"http://example.com/a"}, []string{"to a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, sources, targets, err := linkStore.GetGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 2 {
+		t.Fatalf("expected 2 links, got %v -> %v", sources, targets)
+	}
+	for i := range sources {
+		if sources[i]+targets[i] != 3 {
+			t.Errorf("unexpected link %v -> %v", sources[i], targets[i])
+		}
+	}
+
+	anchors, err := linkStore.GetAnchors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anchors[1]) != 1 || anchors[1][0] != "to a" || len(anchors[2]) != 1 || anchors[2][0] != "to b" {
+		t.Errorf("unexpected anchors %v", anchors)
+	}
+}
+
+func TestKeysAreAddedToOldTables(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec(`
+	CREATE TABLE crawl_urls (url TEXT PRIMARY KEY, id INTEGER, depth INTEGER, state INTEGER);
+	CREATE TABLE links (source INTEGER, target TEXT, text TEXT, PRIMARY KEY(source, target));
+	INSERT INTO crawl_urls VALUES ('https://example.com/a', 1, 0, 2);
+	INSERT INTO links VALUES (2, 'http://example.com/a', 'to a');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documentStore, err := NewSQLDocumentStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSQLCrawlStore(db); err != nil {
+		t.Fatal(err)
+	}
+	linkStore, err := NewSQLLinkStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putDocument(t, documentStore, 1, "https://example.com/a")
+
+	anchors, err := linkStore.GetAnchors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anchors[1]) != 1 || anchors[1][0] != "to a" {
+		t.Errorf("unexpected anchors %v", anchors)
+	}
+}
+
+func TestURLKey(t *testing.T) {
+	tests := map[string]string{
+		"http://example.com/a":  "https://example.com/a",
+		"https://example.com/a": "https://example.com/a",
+		"ftp://example.com/a":   "ftp://example.com/a",
+	}
+	for link, want := range tests {
+		if got := URLKey(link); got != want {
+			t.Errorf("URLKey(%q) = %q, want %q", link, got, want)
+		}
+	}
+}
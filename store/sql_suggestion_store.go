@@ -0,0 +1,159 @@
+package store
+
+import (
+	"database/sql"
+)
+
+type SQLSuggestionStore struct {
+	db             *sql.DB
+	putQueryStmt   *sql.Stmt
+	getTermsStmt   *sql.Stmt
+	getQueriesStmt *sql.Stmt
+}
+
+func NewSQLSuggestionStore(db *sql.DB) (*SQLSuggestionStore, error) {
+	store := &SQLSuggestionStore{
+		db: db,
+	}
+
+	// Create tables if they don't exist
+	err := store.createTables()
+	if err != nil {
+		return nil, err
+	}
+
+	store.putQueryStmt, err = db.Prepare("INSERT INTO suggestion_queries (query, count) VALUES (?, 1) ON CONFLICT (query) DO UPDATE SET count = count + 1")
+	if err != nil {
+		return nil, err
+	}
+
+	// A range instead of LIKE, so that the primary key can be used
+	store.getTermsStmt, err = db.Prepare("SELECT term FROM suggestion_terms WHERE term >= ? AND term < ? ORDER BY frequency DESC, term LIMIT ?")
+	if err != nil {
+		return nil, err
+	}
+
+	store.getQueriesStmt, err = db.Prepare("SELECT query FROM suggestion_queries WHERE query >= ? AND query < ? ORDER BY count DESC, query LIMIT ?")
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (s *SQLSuggestionStore) createTables() error {
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS suggestion_terms (
+		term TEXT PRIMARY KEY,
+		frequency INTEGER
+	);
+	CREATE TABLE IF NOT EXISTS suggestion_queries (
+		query TEXT PRIMARY KEY,
+		count INTEGER
+	);
+	`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLSuggestionStore) PutTerms(terms []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO suggestion_terms (term, frequency) VALUES (?, 1) ON CONFLICT (term) DO UPDATE SET frequency = frequency + 1")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, term := range terms {
+		_, err := stmt.Exec(term)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLSuggestionStore) PutQuery(query string) error {
+	_, err := s.putQueryStmt.Exec(query)
+	return err
+}
+
+func (s *SQLSuggestionStore) GetTerms(prefix string, number int) ([]string, error) {
+	return s.getPrefixed(s.getTermsStmt, prefix, number)
+}
+
+func (s *SQLSuggestionStore) GetQueries(prefix string, number int) ([]string, error) {
+	return s.getPrefixed(s.getQueriesStmt, prefix, number)
+}
+
+func (s *SQLSuggestionStore) GetAllTerms() ([]string, []int64, error) {
+	rows, err := s.db.Query("SELECT term, frequency FROM suggestion_terms")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var terms []string
+	var frequencies []int64
+
+	for rows.Next() {
+		var term string
+		var frequency int64
+
+		err := rows.Scan(&term, &frequency)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		terms = append(terms, term)
+		frequencies = append(frequencies, frequency)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return terms, frequencies, nil
+}
+
+func (s *SQLSuggestionStore) getPrefixed(stmt *sql.Stmt, prefix string, number int) ([]string, error) {
+	// No UTF-8 string contains the byte 0xff, so every string starting with
+	// the prefix sorts before this one
+	rows, err := stmt.Query(prefix, prefix+"\xff", number)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []string{}
+	for rows.Next() {
+		var result string
+		err := rows.Scan(&result)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
@@ -0,0 +1,14 @@
+package store
+
+type SuggestionStore interface {
+	// Counts the terms of a document, every document must only be put once
+	PutTerms(terms []string) error
+	// Counts a query that was searched for
+	PutQuery(query string) error
+	// The terms starting with the prefix, the most frequent first
+	GetTerms(prefix string, number int) ([]string, error)
+	// The past queries starting with the prefix, the most frequent first
+	GetQueries(prefix string, number int) ([]string, error)
+	// All terms and the number of documents they are in
+	GetAllTerms() ([]string, []int64, error)
+}
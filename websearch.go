@@ -3,11 +3,18 @@ package main
 import (
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"os"
 	"runtime/pprof"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/flofriday/websearch/cmd"
+	"github.com/flofriday/websearch/curate"
+	"github.com/flofriday/websearch/query"
+	"github.com/flofriday/websearch/rank"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/urfave/cli/v2"
 )
@@ -32,6 +39,64 @@ func main() {
 						Value: "./index.db",
 						Usage: "Path of the sqlite file",
 					},
+					&cli.BoolFlag{
+						Name:  "resume",
+						Value: false,
+						Usage: "Continue the crawl in the sqlite file instead of starting a new one",
+					},
+					&cli.StringSliceFlag{
+						Name:  "seed",
+						Usage: "An url to start crawling from, can be repeated",
+					},
+					&cli.StringFlag{
+						Name:  "seeds-file",
+						Usage: "A file with one seed url per line, use - for stdin",
+					},
+					&cli.StringSliceFlag{
+						Name:  "allow-domain",
+						Usage: "Only crawl this domain and its subdomains, can be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "deny-domain",
+						Usage: "Never crawl this domain and its subdomains, can be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "include",
+						Usage: "Only crawl paths matching this glob (or regex with the re: prefix), can be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "exclude",
+						Usage: "Never crawl paths matching this glob (or regex with the re: prefix), can be repeated",
+					},
+					&cli.IntFlag{
+						Name:  "max-depth",
+						Value: -1,
+						Usage: "The maximum number of links away from a seed, -1 for no limit",
+					},
+					&cli.IntFlag{
+						Name:  "max-per-host",
+						Value: 2,
+						Usage: "The maximum number of parallel requests to a single host",
+					},
+					&cli.DurationFlag{
+						Name:  "host-delay",
+						Value: 250 * time.Millisecond,
+						Usage: "The minimum delay between two requests to the same host (a larger Crawl-delay in robots.txt wins)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "allow-net",
+						Usage: "Allow crawling a private network (CIDR), which is blocked by default",
+					},
+					&cli.Int64Flag{
+						Name:  "max-body-size",
+						Value: 5 * 1024 * 1024,
+						Usage: "The maximum size of a page in bytes, larger ones are skipped",
+					},
+					&cli.IntFlag{
+						Name:  "duplicate-distance",
+						Value: 3,
+						Usage: "Skip pages whose SimHash differs in at most this many bits from an indexed one, -1 to disable",
+					},
 					&cli.BoolFlag{
 						Name:  "profile",
 						Value: false,
@@ -48,7 +113,82 @@ func main() {
 						defer pprof.StopCPUProfile()
 					}
 
-					cmd.CrawlAndIndex(cCtx.Int64("number"), cCtx.String("sqlite"))
+					allowedNets := []*net.IPNet{}
+					for _, cidr := range cCtx.StringSlice("allow-net") {
+						_, ipNet, err := net.ParseCIDR(cidr)
+						if err != nil {
+							return fmt.Errorf("invalid network '%v': %w", cidr, err)
+						}
+						allowedNets = append(allowedNets, ipNet)
+					}
+
+					scope := &curate.Scope{
+						AllowedDomains: cCtx.StringSlice("allow-domain"),
+						DeniedDomains:  cCtx.StringSlice("deny-domain"),
+						MaxDepth:       cCtx.Int("max-depth"),
+					}
+					for _, pattern := range cCtx.StringSlice("include") {
+						re, err := curate.CompilePattern(pattern)
+						if err != nil {
+							return fmt.Errorf("invalid pattern '%v': %w", pattern, err)
+						}
+						scope.Include = append(scope.Include, re)
+					}
+					for _, pattern := range cCtx.StringSlice("exclude") {
+						re, err := curate.CompilePattern(pattern)
+						if err != nil {
+							return fmt.Errorf("invalid pattern '%v': %w", pattern, err)
+						}
+						scope.Exclude = append(scope.Exclude, re)
+					}
+
+					cmd.CrawlAndIndex(cmd.CrawlOptions{
+						DocLimit:             cCtx.Int64("number"),
+						SqliteFile:           cCtx.String("sqlite"),
+						Resume:               cCtx.Bool("resume"),
+						Seeds:                cCtx.StringSlice("seed"),
+						SeedsFile:            cCtx.String("seeds-file"),
+						Scope:                scope,
+						MaxPerHost:           cCtx.Int("max-per-host"),
+						HostDelay:            cCtx.Duration("host-delay"),
+						AllowedNets:          allowedNets,
+						MaxBodySize:          cCtx.Int64("max-body-size"),
+						MaxDuplicateDistance: cCtx.Int("duplicate-distance"),
+					})
+					return nil
+				},
+			},
+			{
+				Name:  "rank",
+				Usage: "compute the PageRank of the indexed documents",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "sqlite",
+						Value: "./index.db",
+						Usage: "Path of the sqlite file",
+					},
+					&cli.Float64Flag{
+						Name:  "damping",
+						Value: 0.85,
+						Usage: "The probability to follow a link instead of jumping to a random page",
+					},
+					&cli.Float64Flag{
+						Name:  "epsilon",
+						Value: 1e-6,
+						Usage: "Stop once the ranks change less than this",
+					},
+					&cli.IntFlag{
+						Name:  "max-iterations",
+						Value: 100,
+						Usage: "Stop after this many iterations even if the ranks haven't converged",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					cmd.Rank(cCtx.String("sqlite"), rank.Options{
+						Damping:       cCtx.Float64("damping"),
+						Epsilon:       cCtx.Float64("epsilon"),
+						MaxIterations: cCtx.Int("max-iterations"),
+					})
 					return nil
 				},
 			},
@@ -66,9 +206,17 @@ func main() {
 						Value: "./index.db",
 						Usage: "Path of the sqlite file",
 					},
+					&cli.StringSliceFlag{
+						Name:  "boost",
+						Usage: "Weight a field (body, anchor, title, heading, url, description) like title=3, can be repeated",
+					},
 				},
 				Action: func(cCtx *cli.Context) error {
-					cmd.Serve(cCtx.String("addr"), cCtx.String("sqlite"))
+					boosts, err := parseBoosts(cCtx.StringSlice("boost"))
+					if err != nil {
+						return err
+					}
+					cmd.Serve(cCtx.String("addr"), cCtx.String("sqlite"), boosts)
 					return nil
 				},
 			},
@@ -82,6 +230,24 @@ func main() {
 						Value: "./index.db",
 						Usage: "Path of the sqlite file",
 					},
+					&cli.StringFlag{
+						Name:  "lang",
+						Usage: "Only find documents in this language (like en or de)",
+					},
+					&cli.IntFlag{
+						Name:  "limit",
+						Value: 6,
+						Usage: "The number of results per page",
+					},
+					&cli.IntFlag{
+						Name:  "page",
+						Value: 1,
+						Usage: "The page of the results to show",
+					},
+					&cli.StringSliceFlag{
+						Name:  "boost",
+						Usage: "Weight a field (body, anchor, title, heading, url, description) like title=3, can be repeated",
+					},
 				},
 				Action: func(cCtx *cli.Context) error {
 					if len(cCtx.Args().Slice()) == 0 {
@@ -89,7 +255,25 @@ func main() {
 						fmt.Fprintln(os.Stderr, "Run 'websearch search --help' for more infos.")
 						return nil
 					}
-					cmd.Search(cCtx.String("sqlite"), strings.Join(cCtx.Args().Slice(), " "))
+					boosts, err := parseBoosts(cCtx.StringSlice("boost"))
+					if err != nil {
+						return err
+					}
+					if cCtx.Int("limit") < 1 || cCtx.Int("page") < 1 {
+						return fmt.Errorf("limit and page must be at least 1")
+					}
+					// The offset of the results must not overflow
+					if cCtx.Int("page") > cmd.MAX_PAGE || cCtx.Int("page")-1 > math.MaxInt/cCtx.Int("limit") {
+						return fmt.Errorf("page must be at most %v", cmd.MAX_PAGE)
+					}
+					cmd.Search(cmd.SearchOptions{
+						SqliteFile: cCtx.String("sqlite"),
+						Query:      strings.Join(cCtx.Args().Slice(), " "),
+						Language:   cCtx.String("lang"),
+						Limit:      cCtx.Int("limit"),
+						Page:       cCtx.Int("page"),
+						Boosts:     boosts,
+					})
 					return nil
 				},
 			},
@@ -100,3 +284,21 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// Parses field boosts given as field=weight.
+func parseBoosts(values []string) (map[int]float64, error) {
+	boosts := map[int]float64{}
+	for _, value := range values {
+		name, weight, ok := strings.Cut(value, "=")
+		field, known := query.ParseField(name)
+		if !ok || !known {
+			return nil, fmt.Errorf("invalid boost '%v', expected field=weight", value)
+		}
+		boost, err := strconv.ParseFloat(weight, 64)
+		if err != nil || boost < 0 {
+			return nil, fmt.Errorf("invalid boost '%v', expected field=weight", value)
+		}
+		boosts[field] = boost
+	}
+	return boosts, nil
+}